@@ -9,7 +9,7 @@ import (
 
 	_ "github.com/natsukagami/kjudge"
 	"github.com/natsukagami/kjudge/db"
-	_ "github.com/natsukagami/kjudge/models"
+	"github.com/natsukagami/kjudge/models"
 	"github.com/natsukagami/kjudge/server"
 	"github.com/natsukagami/kjudge/worker"
 )
@@ -19,13 +19,29 @@ var (
 	sandboxImpl = flag.String("sandbox", "isolate", "The sandbox implementation to be used (isolate, raw). Defaults to isolate.")
 	port        = flag.Int("port", 8088, "The port for the server to listen on.")
 	verbose     = flag.Bool("verbose", false, "Log every http requests")
+	workers     = flag.Int("workers", 1, "The number of judging workers running in parallel. Each worker uses its own sandbox box. With 0, only remote workers judge submissions, and a single local worker still runs the admins' tasks (e.g. generating outputs).")
+	workerToken = flag.String("worker_token", "", "The token remote workers (\"kjudge worker\") must present to take jobs. If omitted or empty, remote workers are disabled.")
+	keptOutput  = flag.Int("kept_output", worker.DefaultKeptOutput, "The amount of the submissions' output and standard error kept on each test for the admins, in KBs.")
+	languages   = flag.String("languages", "", "Path to the language definitions file. Defaults to \"languages.toml\" next to the database file if it exists, or the built-in definitions otherwise.")
 
 	httpsDir = flag.String("https", "", "Path to the directory where the HTTPS private key (kjudge.key) and certificate (kjudge.crt) is located. If omitted or empty, HTTPS is disabled.")
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "worker" {
+		workerMain(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "strip_outputs" {
+		stripOutputsMain(os.Args[2:])
+		return
+	}
 	flag.Parse()
 
+	if err := loadLanguages(*languages, filepath.Join(filepath.Dir(*dbfile), "languages.toml")); err != nil {
+		log.Fatalf("%+v", err)
+	}
+
 	db, err := db.New(*dbfile)
 	if err != nil {
 		log.Fatalf("%+v", err)
@@ -41,9 +57,13 @@ func main() {
 	if *verbose {
 		opts = append(opts, server.Verbose())
 	}
+	if *workerToken != "" {
+		opts = append(opts, server.RemoteWorkers(*workerToken))
+	}
+	opts = append(opts, server.KeptOutput(*keptOutput))
 
 	// Start the queue
-	queue := worker.Queue{Sandbox: sandbox, DB: db}
+	queue := worker.Queue{Sandbox: sandbox, DB: db, Workers: *workers, AdminTasksOnly: *workers < 1, KeptOutput: *keptOutput}
 
 	// Build the server
 	server, err := server.New(db, opts...)
@@ -58,6 +78,7 @@ func main() {
 
 	go sandbox.Start()
 	go queue.Start()
+	go worker.WatchSystemTests(db)
 	go startServer(server)
 
 	received_signal := <-stop
@@ -65,6 +86,19 @@ func main() {
 	log.Printf("Shutting down on receiving %s", received_signal)
 }
 
+// Loads the language definitions from `path`, or from `fallback` if `path` is empty and `fallback` exists.
+// Keeps the built-in definitions if there is neither.
+func loadLanguages(path, fallback string) error {
+	if path == "" {
+		if _, err := os.Stat(fallback); err != nil {
+			return nil
+		}
+		path = fallback
+	}
+	log.Printf("Loading language definitions from %s", path)
+	return models.LoadLanguages(path)
+}
+
 func startServer(server *server.Server) {
 	var err error
 	if *httpsDir == "" {
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"flag"
+	"log"
+
+	"github.com/natsukagami/kjudge/db"
+	"github.com/natsukagami/kjudge/models"
+)
+
+// Runs "kjudge strip_outputs", which removes the kept outputs of the test results after the contests.
+func stripOutputsMain(args []string) {
+	flags := flag.NewFlagSet("kjudge strip_outputs", flag.ExitOnError)
+	var (
+		dbfile    = flags.String("file", "kjudge.db", "Path to the database file.")
+		contestID = flags.Int("contest", 0, "The ID of the contest to strip the outputs of. If omitted, all finished contests are stripped.")
+	)
+	_ = flags.Parse(args)
+
+	db, err := db.New(*dbfile)
+	if err != nil {
+		log.Fatalf("%+v", err)
+	}
+	defer db.Close()
+
+	var contests []*models.Contest
+	if *contestID != 0 {
+		contest, err := models.GetContest(db, *contestID)
+		if err != nil {
+			log.Fatalf("Cannot find contest %d: %+v", *contestID, err)
+		}
+		contests = append(contests, contest)
+	} else if contests, err = models.GetContestsFinished(db); err != nil {
+		log.Fatalf("%+v", err)
+	}
+
+	for _, contest := range contests {
+		stripped, err := models.StripContestTestResultOutputs(db, contest.ID)
+		if err != nil {
+			log.Fatalf("%+v", err)
+		}
+		log.Printf("Stripped the outputs of %d test results from contest %d (%s)", stripped, contest.ID, contest.Name)
+	}
+}
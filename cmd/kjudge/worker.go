@@ -0,0 +1,58 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+
+	"github.com/natsukagami/kjudge/worker"
+	"github.com/natsukagami/kjudge/worker/remote"
+)
+
+// Runs "kjudge worker", a remote judge worker taking jobs from a main kjudge server.
+func workerMain(args []string) {
+	hostname, _ := os.Hostname()
+	flags := flag.NewFlagSet("kjudge worker", flag.ExitOnError)
+	var (
+		serverURL   = flags.String("server", "", "The URL of the main kjudge server, e.g. http://10.0.0.1:8088.")
+		token       = flags.String("token", "", "The worker token, as given to the main server with -worker_token.")
+		name        = flags.String("name", hostname, "The name of this worker, shown on the main server. Defaults to the hostname.")
+		sandboxImpl = flags.String("sandbox", "isolate", "The sandbox implementation to be used (isolate, raw). Defaults to isolate.")
+		workers     = flags.Int("workers", 1, "The number of jobs done in parallel. Each one uses its own sandbox box.")
+		languages   = flags.String("languages", "", "Path to the language definitions file. Should be the same as the main server's. Defaults to \"languages.toml\" in the current directory if it exists, or the built-in definitions otherwise.")
+	)
+	_ = flags.Parse(args)
+	if *serverURL == "" || *token == "" {
+		log.Fatalf("Both -server and -token are required")
+	}
+	if err := loadLanguages(*languages, "languages.toml"); err != nil {
+		log.Fatalf("%+v", err)
+	}
+
+	sandbox, err := worker.NewSandbox(*sandboxImpl)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	go sandbox.Start()
+
+	for id := 0; id < *workers; id++ {
+		workerName := *name
+		if *workers > 1 {
+			workerName = fmt.Sprintf("%s/%d", *name, id)
+		}
+		w := &remote.Worker{
+			Client:  remote.NewClient(*serverURL, *token, workerName, *sandboxImpl),
+			Sandbox: sandbox.Box(id),
+		}
+		go w.Start()
+	}
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt)
+	log.Println("Starting kjudge worker. Press Ctrl+C to stop")
+
+	received_signal := <-stop
+	log.Printf("Shutting down on receiving %s", received_signal)
+}
@@ -30,7 +30,9 @@ type DB struct {
 
 // New creates a new DB object from the given filename.
 func New(filename string) (*DB, error) {
-	dsn := fmt.Sprintf("%s?_fk=1&mode=rw&cache=shared&_journal=WAL&_busy_timeout=10000&_sync=NORMAL", filename)
+	// Transactions take the write lock as soon as they begin (_txlock=immediate). Otherwise a transaction
+	// that reads before writing fails right away when someone else (e.g. another worker) wrote in between.
+	dsn := fmt.Sprintf("%s?_fk=1&mode=rw&cache=shared&_journal=WAL&_busy_timeout=10000&_sync=NORMAL&_txlock=immediate", filename)
 	sqlxdb, err := sqlx.Open("sqlite3", dsn)
 	if err != nil {
 		return nil, errors.WithStack(err)
@@ -7,6 +7,8 @@ import (
 	"path"
 	"regexp"
 	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/natsukagami/kjudge/embed"
 	"github.com/pkg/errors"
@@ -37,7 +39,7 @@ func (db *DB) migrate() error {
 
 	if version != "" {
 		// Filter away the versions that are already migrated
-		for len(versions) > 0 && versions[0] <= version {
+		for len(versions) > 0 && versionNumber(versions[0]) <= versionNumber(version) {
 			versions = versions[1:]
 		}
 	}
@@ -89,6 +91,15 @@ func getSchemaFiles() ([]string, error) {
 			names = append(names, matches[0][1])
 		}
 	}
-	sort.Slice(names, func(i, j int) bool { return names[i] < names[j] })
+	sort.Slice(names, func(i, j int) bool { return versionNumber(names[i]) < versionNumber(names[j]) })
 	return names, nil
 }
+
+// Gets the number of the schema version "vN", so that "v10" comes after "v9".
+func versionNumber(version string) int {
+	n, err := strconv.Atoi(strings.TrimPrefix(version, "v"))
+	if err != nil {
+		return 0
+	}
+	return n
+}
@@ -0,0 +1,3 @@
+-- The languages accepted in a contest, as a comma-separated list of language IDs.
+-- An empty list accepts every available language.
+ALTER TABLE contests ADD COLUMN languages VARCHAR NOT NULL DEFAULT "";
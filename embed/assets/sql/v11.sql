@@ -0,0 +1,3 @@
+-- The languages accepted for a problem, as a comma-separated list of language IDs.
+-- An empty list accepts every language the contest accepts.
+ALTER TABLE problems ADD COLUMN languages VARCHAR NOT NULL DEFAULT "";
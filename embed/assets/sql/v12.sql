@@ -0,0 +1,13 @@
+-- Per-problem overrides of the languages' time and memory limit adjustments.
+CREATE TABLE problem_language_limits (
+    id INTEGER PRIMARY KEY NOT NULL,
+    problem_id INTEGER NOT NULL,
+    language VARCHAR NOT NULL,
+    time_multiplier REAL NOT NULL DEFAULT 1,
+    time_offset INTEGER NOT NULL DEFAULT 0,
+    memory_multiplier REAL NOT NULL DEFAULT 1,
+    memory_offset INTEGER NOT NULL DEFAULT 0,
+
+    UNIQUE(problem_id, language),
+    FOREIGN KEY(problem_id) REFERENCES problems(id) ON DELETE CASCADE
+);
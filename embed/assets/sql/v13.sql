@@ -0,0 +1,5 @@
+-- How the outputs are compared when the problem has no "compare" or "checker" file.
+-- The epsilons are used by the "numeric" mode.
+ALTER TABLE problems ADD COLUMN comparison_mode VARCHAR NOT NULL DEFAULT "diff";
+ALTER TABLE problems ADD COLUMN comparison_abs_epsilon REAL NOT NULL DEFAULT 0.000001;
+ALTER TABLE problems ADD COLUMN comparison_rel_epsilon REAL NOT NULL DEFAULT 0.000001;
@@ -0,0 +1,11 @@
+-- The typed verdict of each test result, next to the verdict message.
+ALTER TABLE test_results ADD COLUMN verdict_code VARCHAR NOT NULL DEFAULT "WA";
+
+-- Guess the codes of the existing results from their scores and messages.
+UPDATE test_results SET verdict_code = (CASE
+    WHEN score >= 1 THEN "AC"
+    WHEN score > 0 THEN "PA"
+    WHEN verdict LIKE "Time limit exceeded%" OR verdict = "Command timed out" THEN "TLE"
+    WHEN verdict = "Runtime Error" OR verdict LIKE "Exited with error status%" OR verdict LIKE "Caught fatal signal%" THEN "RE"
+    ELSE "WA"
+END);
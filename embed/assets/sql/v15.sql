@@ -0,0 +1,2 @@
+-- The internal error of submissions with the "Judge Error" verdict, shown to the admins.
+ALTER TABLE submissions ADD COLUMN judge_error TEXT NOT NULL DEFAULT "";
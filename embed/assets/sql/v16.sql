@@ -0,0 +1,5 @@
+-- The beginning of the submission's output and standard error on each test, and the full message of the checker,
+-- kept for the admins. They can be stripped with "kjudge strip_outputs" after the contest.
+ALTER TABLE test_results ADD COLUMN output BLOB;
+ALTER TABLE test_results ADD COLUMN stderr BLOB;
+ALTER TABLE test_results ADD COLUMN checker_message TEXT NOT NULL DEFAULT "";
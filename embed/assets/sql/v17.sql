@@ -0,0 +1,2 @@
+-- How much of the judging results the contestants see for the problem's submissions.
+ALTER TABLE problems ADD COLUMN feedback_level VARCHAR NOT NULL DEFAULT "full";
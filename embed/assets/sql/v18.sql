@@ -0,0 +1,4 @@
+-- Whether the contestants see the test group: "visible", "hidden_during_contest" (revealed when the contest ends)
+-- or "hidden_forever". Hidden test groups used to have a negative score, which did not count.
+ALTER TABLE test_groups ADD COLUMN visibility VARCHAR NOT NULL DEFAULT "visible";
+UPDATE test_groups SET visibility = "hidden_forever", score = 0 WHERE score < 0;
@@ -0,0 +1,8 @@
+-- Pretests and system tests (Codeforces-style two-phase judging).
+-- In contests with system tests, submissions are only judged on the pretest groups until the system tests start,
+-- when the contest ends or from the admin panel. Then the best submission of each contestant on each problem
+-- is judged on every test, and only these submissions count.
+ALTER TABLE contests ADD COLUMN system_tests INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE contests ADD COLUMN system_tests_started INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE test_groups ADD COLUMN pretest INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE submissions ADD COLUMN system_test INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,4 @@
+-- Whether the remaining tests of a "min" or "product" test group are skipped after its first zero-scored test,
+-- as the group scores zero anyway. Skipped tests get a "Skipped" test result.
+ALTER TABLE test_groups ADD COLUMN skip_after_failure INTEGER NOT NULL DEFAULT 0;
+UPDATE test_groups SET skip_after_failure = 1 WHERE scoring_mode IN ("min", "product");
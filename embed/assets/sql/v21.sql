@@ -0,0 +1,3 @@
+-- The test groups (of the same problem) a test group depends on, as a comma-separated list of their IDs.
+-- The score of a test group is capped by the results of its dependencies, whose tests are not copied into it.
+ALTER TABLE test_groups ADD COLUMN dependencies VARCHAR NOT NULL DEFAULT "";
@@ -0,0 +1,4 @@
+-- Output-only problems, whose submissions are archives of the outputs of the tests instead of code.
+-- The output of each test is the file matching output_pattern, with the "?" standing for the test's name.
+ALTER TABLE problems ADD COLUMN output_only INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE problems ADD COLUMN output_pattern VARCHAR NOT NULL DEFAULT "output?.txt";
@@ -0,0 +1,4 @@
+-- File-based I/O: the submissions read the test's input from input_file instead of the standard input,
+-- and write their output to output_file instead of the standard output. Empty means the standard input / output.
+ALTER TABLE problems ADD COLUMN input_file VARCHAR NOT NULL DEFAULT "";
+ALTER TABLE problems ADD COLUMN output_file VARCHAR NOT NULL DEFAULT "";
@@ -0,0 +1,3 @@
+-- The problem file holding the reference solution, which generates the expected outputs of the tests.
+-- Empty means the problem has no reference solution.
+ALTER TABLE problems ADD COLUMN reference_solution VARCHAR NOT NULL DEFAULT "";
@@ -0,0 +1,4 @@
+-- The result of running the problem's validator on the test input: "" if not validated yet, "valid" or "invalid".
+ALTER TABLE tests ADD COLUMN validation VARCHAR NOT NULL DEFAULT "";
+-- Why the validator rejected the test input.
+ALTER TABLE tests ADD COLUMN validation_message VARCHAR NOT NULL DEFAULT "";
@@ -0,0 +1,16 @@
+-- Model solutions of the problems, judged by the admins against the tests along with the outcome they are expected
+-- to get. They are not submissions: they never make it into the results nor the scoreboard.
+CREATE TABLE model_solutions (
+    id INTEGER PRIMARY KEY NOT NULL,
+    problem_id INTEGER NOT NULL,
+    name VARCHAR NOT NULL,
+    language VARCHAR NOT NULL,
+    source BLOB NOT NULL,
+    -- One of "accepted", "wrong_answer", "time_limit" and "scores".
+    expected VARCHAR NOT NULL,
+    -- The expected score of each test group, as a comma-separated list of "name=score", if expected is "scores".
+    expected_scores VARCHAR NOT NULL DEFAULT "",
+
+    UNIQUE(problem_id, name),
+    FOREIGN KEY(problem_id) REFERENCES problems(id) ON DELETE CASCADE
+);
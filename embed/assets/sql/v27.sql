@@ -0,0 +1,2 @@
+-- Whether the contest is held (the contestants cannot see it start) while its pre-flight check finds errors.
+ALTER TABLE contests ADD COLUMN preflight_hold INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,13 @@
+-- The results the contestants see until the contest ends: on the test groups visible during the contest only.
+-- The visible score of a submission is only meaningful when it is scored.
+ALTER TABLE submissions ADD COLUMN visible_score REAL DEFAULT NULL;
+ALTER TABLE submissions ADD COLUMN visible_verdict VARCHAR NOT NULL DEFAULT "";
+UPDATE submissions SET visible_score = score, visible_verdict = verdict;
+
+ALTER TABLE problem_results ADD COLUMN visible_solved INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE problem_results ADD COLUMN visible_score REAL NOT NULL DEFAULT 0;
+ALTER TABLE problem_results ADD COLUMN visible_penalty INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE problem_results ADD COLUMN visible_best_submission_id INTEGER DEFAULT NULL REFERENCES submissions(id) ON DELETE CASCADE;
+ALTER TABLE problem_results ADD COLUMN visible_failed_attempts INTEGER NOT NULL DEFAULT 0;
+UPDATE problem_results SET visible_solved = solved, visible_score = score, visible_penalty = penalty,
+    visible_best_submission_id = best_submission_id, visible_failed_attempts = failed_attempts;
@@ -0,0 +1,20 @@
+-- Tasks the admins run on a problem in the background, on the local workers, e.g. generating the expected outputs of
+-- its tests. Their reports are kept (as JSON) until the same task is run again.
+CREATE TABLE admin_tasks (
+    id INTEGER PRIMARY KEY NOT NULL,
+    problem_id INTEGER NOT NULL,
+    type VARCHAR NOT NULL,
+    -- The test group the task is limited to, NULL for all test groups of the problem.
+    test_group_id INTEGER DEFAULT NULL,
+    created_at DATETIME NOT NULL,
+    claimed_by VARCHAR DEFAULT NULL,
+    finished_at DATETIME DEFAULT NULL,
+    -- Why the task could not be done, empty if it was.
+    error VARCHAR NOT NULL DEFAULT "",
+    report BLOB DEFAULT NULL,
+
+    FOREIGN KEY(problem_id) REFERENCES problems(id) ON DELETE CASCADE,
+    FOREIGN KEY(test_group_id) REFERENCES test_groups(id) ON DELETE CASCADE
+);
+
+CREATE INDEX admin_tasks_by_problem ON admin_tasks (problem_id);
@@ -0,0 +1,4 @@
+-- Whether the start of the contest is held by the errors of its pre-flight check. The check is run once when the
+-- contest starts (hold_checked), and again when the admins release the hold.
+ALTER TABLE contests ADD COLUMN held INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE contests ADD COLUMN hold_checked INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,5 @@
+-- Jobs are claimed by a worker before being run, so that multiple workers
+-- never take the same job.
+ALTER TABLE jobs ADD COLUMN claimed_by VARCHAR DEFAULT NULL;
+
+CREATE INDEX jobs_by_submission ON jobs (submission_id);
@@ -0,0 +1,11 @@
+-- Claims on jobs are leases, which expire unless the worker keeps extending them.
+-- Failed jobs are retried with backoff, up to a limit, before becoming "dead".
+BEGIN TRANSACTION;
+
+ALTER TABLE jobs ADD COLUMN claim_expires_at DATETIME DEFAULT NULL;
+ALTER TABLE jobs ADD COLUMN attempts INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE jobs ADD COLUMN run_after DATETIME DEFAULT NULL;
+ALTER TABLE jobs ADD COLUMN last_error VARCHAR NOT NULL DEFAULT "";
+ALTER TABLE jobs ADD COLUMN dead INTEGER NOT NULL DEFAULT 0;
+
+COMMIT;
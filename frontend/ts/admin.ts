@@ -17,3 +17,39 @@
     setInterval(update, 10 * 1000);
     update();
 })();
+
+// Submissions with a Judge Error
+(() => {
+    const banner = document.getElementById("judge-error-banner");
+    const count = document.getElementById("judge-error-count");
+    const list = document.getElementById("judge-error-list");
+    const ids = document.getElementById("judge-error-ids") as HTMLInputElement | null;
+    if (!banner || !count || !list || !ids) return;
+    type JudgeError = { id: number; link: string; error: string };
+    const update = () => {
+        fetch("/admin/judge_errors")
+            .then((res) => res.json())
+            .then((errors: JudgeError[]) => {
+                if (errors.length === 0) {
+                    banner.classList.add("hidden");
+                    return;
+                }
+                banner.classList.remove("hidden");
+                count.textContent = errors.length.toString();
+                ids.value = errors.map((e) => e.id).join(",");
+                list.innerHTML = "";
+                for (const e of errors) {
+                    const item = document.createElement("li");
+                    const link = document.createElement("a");
+                    link.href = e.link;
+                    link.className = "font-semibold hover:text-blue-600";
+                    link.textContent = `#${e.id}`;
+                    item.appendChild(link);
+                    item.appendChild(document.createTextNode(`: ${e.error}`));
+                    list.appendChild(item);
+                }
+            });
+    };
+    setInterval(update, 10 * 1000);
+    update();
+})();
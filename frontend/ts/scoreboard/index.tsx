@@ -42,6 +42,7 @@ interface ProblemResult {
     penalty: number;
     failed_attempts: number;
     best_submission: number;
+    hidden?: boolean;
 }
 
 /**
@@ -225,7 +226,10 @@ const Cell = ({
     let bg_class = "";
     let title: string = "";
 
-    if (contest_type === "unweighted") {
+    if (result.hidden) {
+        score = "?";
+        title = "hidden until the end of the contest";
+    } else if (contest_type === "unweighted") {
         if (result.solved) {
             score = `+${
                 result.failed_attempts > 0 ? result.failed_attempts : ""
@@ -21,10 +21,11 @@ hs.highlightAll();
 (() => {
     type Result =
         | {
-              verdict: "..." | "Compile Error";
+              verdict: "..." | "Compile Error" | "Judge Error" | "Judged";
           }
         | {
-              verdict: "Scored" | "Accepted";
+              // "Scored", "Accepted" or the verdict of the first failed test, e.g. "Wrong Answer".
+              verdict: string;
               score: number;
               penalty: number;
           };
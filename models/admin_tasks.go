@@ -0,0 +1,132 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/natsukagami/kjudge/db"
+	"github.com/natsukagami/kjudge/models/verify"
+	"github.com/pkg/errors"
+)
+
+// AdminTaskType is the type of a task the admins run on a problem in the background. This can be:
+// - Generate: generates the expected outputs of the tests with the problem's reference solution.
+// - Validate: validates the inputs of the tests that are not validated yet with the problem's validator.
+// - Invoke: judges the problem's model solutions against its tests.
+type AdminTaskType string
+
+// All possible values of AdminTaskType.
+const (
+	AdminTaskTypeGenerate AdminTaskType = "generate"
+	AdminTaskTypeValidate AdminTaskType = "validate"
+	AdminTaskTypeInvoke   AdminTaskType = "invoke"
+)
+
+func (t AdminTaskType) verify() error {
+	return verify.String(string(t), verify.Enum(string(AdminTaskTypeGenerate), string(AdminTaskTypeValidate),
+		string(AdminTaskTypeInvoke)))
+}
+
+// NewAdminTask creates a new task of the given type on the problem, limited to the test group if it is valid.
+func NewAdminTask(problemID int, typ AdminTaskType, testGroupID sql.NullInt64) *AdminTask {
+	return &AdminTask{
+		ProblemID:   problemID,
+		Type:        typ,
+		TestGroupID: testGroupID,
+		CreatedAt:   time.Now(),
+	}
+}
+
+// Verify verifies whether a task is a legit task.
+func (r *AdminTask) Verify() error {
+	return verify.All(map[string]error{
+		"Type": r.Type.verify(),
+	})
+}
+
+// Status returns the status of the task, for display.
+func (r *AdminTask) Status() string {
+	switch {
+	case r.FinishedAt.Valid && r.Error != "":
+		return "Failed"
+	case r.FinishedAt.Valid:
+		return "Done"
+	case r.ClaimedBy.Valid:
+		return "Running"
+	default:
+		return "Queued"
+	}
+}
+
+// Done returns whether the task is finished, successfully or not.
+func (r *AdminTask) Done() bool {
+	return r.FinishedAt.Valid
+}
+
+// StartAdminTask queues the task, removing the finished tasks it replaces: the ones of the same type,
+// on the same problem and test group.
+func StartAdminTask(db db.DBContext, task *AdminTask) error {
+	if _, err := db.Exec(`DELETE FROM admin_tasks WHERE finished_at IS NOT NULL AND problem_id = ? AND type = ?
+		AND test_group_id IS ?`, task.ProblemID, task.Type, task.TestGroupID); err != nil {
+		return errors.WithStack(err)
+	}
+	return task.Write(db)
+}
+
+// GetLatestAdminTask returns the latest task of the given type on the problem, limited to the test group if it is valid.
+// Returns nil if there is none.
+func GetLatestAdminTask(db db.DBContext, problemID int, typ AdminTaskType, testGroupID sql.NullInt64) (*AdminTask, error) {
+	var t AdminTask
+	if err := db.Get(&t, `SELECT * FROM admin_tasks WHERE problem_id = ? AND type = ? AND test_group_id IS ?
+		ORDER BY id DESC LIMIT 1`, problemID, typ, testGroupID); errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return &t, nil
+}
+
+// ClaimAdminTask atomically takes the oldest task that is not claimed yet, marking it as claimed by `owner`.
+// Returns nil if there is none.
+func ClaimAdminTask(db db.DBContext, owner string) (*AdminTask, error) {
+	var t AdminTask
+	if err := db.Get(&t, `UPDATE admin_tasks SET claimed_by = ? WHERE id = (
+		SELECT id FROM admin_tasks WHERE claimed_by IS NULL AND finished_at IS NULL ORDER BY id ASC LIMIT 1
+	) RETURNING *`, owner); errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return &t, nil
+}
+
+// ReleaseAdminTasks releases the claims on the tasks that are not finished, so that they are run again.
+// Tasks are only run by the local workers, so their claims are released when the workers start.
+func ReleaseAdminTasks(db db.DBContext) error {
+	_, err := db.Exec("UPDATE admin_tasks SET claimed_by = NULL WHERE finished_at IS NULL")
+	return errors.WithStack(err)
+}
+
+// Finish marks the task as finished, with its report (kept as JSON) if it was done, or why it could not be.
+func (r *AdminTask) Finish(db db.DBContext, report interface{}, cause error) error {
+	r.FinishedAt = sql.NullTime{Time: time.Now(), Valid: true}
+	if cause != nil {
+		r.Error = cause.Error()
+	} else {
+		content, err := json.Marshal(report)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		r.Report = content
+	}
+	return r.Write(db)
+}
+
+// ReadReport reads the report of the finished task into `report`.
+func (r *AdminTask) ReadReport(report interface{}) error {
+	if r.Report == nil {
+		return errors.New("the task has no report")
+	}
+	return errors.WithStack(json.Unmarshal(r.Report, report))
+}
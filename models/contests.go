@@ -2,6 +2,7 @@ package models
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/natsukagami/kjudge/db"
 	"github.com/natsukagami/kjudge/models/verify"
@@ -44,9 +45,44 @@ func (c *Contest) Verify() error {
 		c.ContestType != ContestTypeWeighted {
 		return errors.New("contest type: invalid value")
 	}
+	if err := c.Languages.verify(); err != nil {
+		return errors.WithMessage(err, "languages")
+	}
+	return nil
+}
+
+// RecordHold records whether the start of the contest is held, as found by its pre-flight check when it starts.
+// Only the first record counts: the contest is not checked again until the hold is released.
+func (c *Contest) RecordHold(db db.DBContext, held bool) error {
+	res, err := db.Exec("UPDATE contests SET held = ?, hold_checked = 1 WHERE id = ? AND hold_checked = 0", held, c.ID)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return errors.WithStack(err)
+	} else if n == 0 {
+		// Recorded by someone else in the meantime.
+		return errors.WithStack(db.Get(c, "SELECT * FROM contests WHERE id = ?", c.ID))
+	}
+	c.Held = held
+	c.HoldChecked = true
 	return nil
 }
 
+// ReleaseHold starts the held contest at `now`. Its start and end times are pushed back by the time it was held,
+// so that the contestants get the whole duration of the contest.
+func (c *Contest) ReleaseHold(db db.DBContext, now time.Time) error {
+	if !c.Held {
+		return errors.New("the contest is not held")
+	}
+	if hold := now.Sub(c.StartTime).Truncate(time.Second); hold > 0 {
+		c.StartTime = c.StartTime.Add(hold)
+		c.EndTime = c.EndTime.Add(hold)
+	}
+	c.Held = false
+	return c.Write(db)
+}
+
 // Link returns the HTTP link to the contest.
 func (c *Contest) Link() string {
 	return fmt.Sprintf("/contests/%d", c.ID)
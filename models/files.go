@@ -42,6 +42,41 @@ func (f *File) Compilable() bool {
 	return err == nil
 }
 
+// GraderLanguage returns the language of a grader file, named "grader.<ext>" with <ext> being one of the language's
+// extensions. Returns false if the file is not a grader.
+func (f *File) GraderLanguage() (Language, bool) {
+	ext := filepath.Ext(f.Filename)
+	if strings.TrimSuffix(f.Filename, ext) != "grader" {
+		return "", false
+	}
+	l, err := LanguageByExt(ext)
+	return l, err == nil
+}
+
+// Graders returns the graders among the problem files, by language.
+// Problems with graders only accept submissions in the graders' languages.
+func Graders(files []*File) map[Language]*File {
+	graders := make(map[Language]*File)
+	for _, f := range files {
+		if l, ok := f.GraderLanguage(); ok {
+			graders[l] = f
+		}
+	}
+	return graders
+}
+
+// CompileFiles returns the problem files given to the compilation of submissions: all of them but the reference
+// solution, which contestants must not be able to include.
+func (r *Problem) CompileFiles(files []*File) []*File {
+	var res []*File
+	for _, f := range files {
+		if f.Filename != r.ReferenceSolution {
+			res = append(res, f)
+		}
+	}
+	return res
+}
+
 // WriteFiles writes the given files as brand new, overwritting the old ones.
 // Note that because of overwritting behaviour, we cannot ensure the validity of the indicies, hence they are not reflected into
 // the *Files.
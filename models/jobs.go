@@ -5,6 +5,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/jmoiron/sqlx"
 	"github.com/natsukagami/kjudge/db"
 	"github.com/pkg/errors"
 )
@@ -29,6 +30,19 @@ const (
 	scorePriority   = 1
 )
 
+const (
+	// JobLeaseDuration is how long a claim on a job lasts.
+	// Workers keep extending the claim while working on the job, so a claim only expires
+	// when its worker has stopped responding.
+	JobLeaseDuration = time.Minute
+	// MaxJobAttempts is the number of times a job is tried before it becomes dead.
+	MaxJobAttempts = 5
+)
+
+// ErrJobLost is returned when a worker works on a job it does not hold the claim of anymore,
+// usually because its lease has expired and the job went to someone else.
+var ErrJobLost = errors.New("the job is not claimed by this worker anymore")
+
 const roundHashMod = 10052000 // ;)
 
 func hashSubID(id int) int { return 3 * (roundHashMod - (id % roundHashMod)) }
@@ -78,18 +92,174 @@ func (r *Job) Verify() error {
 	return nil
 }
 
-// FirstJob returns the first job that needs to be done.
-func FirstJob(db db.DBContext) (*Job, error) {
+// ClaimJob atomically takes the first job that is ready to be done, marking it as claimed by `owner`.
+// A job is ready when it is not dead, not waiting to be retried, and no other job of the same submission
+// has to be done before it, that is:
+// - All jobs of the submission with a higher priority (compile before run, run before score) are done.
+// - Compile and Score jobs do not run alongside any other job of the same submission.
+// Returns nil if no jobs are ready.
+func ClaimJob(db db.DBContext, owner string) (*Job, error) {
+	if err := releaseExpiredJobs(db); err != nil {
+		return nil, err
+	}
 	var j Job
-	if err := db.Get(&j, "SELECT * FROM jobs"+queryJobOrderBy+" LIMIT 1"); err != nil {
+	if err := db.Get(&j, `UPDATE jobs SET claimed_by = ?, claim_expires_at = ?, attempts = attempts + 1 WHERE id = (
+		SELECT j.id FROM jobs j WHERE j.claimed_by IS NULL AND NOT j.dead
+			AND (j.run_after IS NULL OR datetime(j.run_after) <= datetime('now'))
+			AND NOT EXISTS (
+				SELECT 1 FROM jobs k WHERE k.submission_id = j.submission_id AND k.id <> j.id
+					AND (k.priority > j.priority OR (k.claimed_by IS NOT NULL AND (k.type <> ? OR j.type <> ?)))
+			)`+queryJobOrderBy+` LIMIT 1
+	) RETURNING *`, owner, time.Now().Add(JobLeaseDuration), JobTypeRun, JobTypeRun); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, nil
 		}
-		return nil, err
+		return nil, errors.WithStack(err)
 	}
 	return &j, nil
 }
 
+// Releases the claims whose worker has stopped responding, killing the jobs that ran out of attempts.
+func releaseExpiredJobs(db db.DBContext) error {
+	res, err := db.Exec(`UPDATE jobs SET claimed_by = NULL, claim_expires_at = NULL, dead = (attempts >= ?), last_error = ?
+		WHERE claimed_by IS NOT NULL AND (claim_expires_at IS NULL OR datetime(claim_expires_at) <= datetime('now'))`,
+		MaxJobAttempts, "The worker stopped responding")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return errors.WithStack(err)
+	} else if n > 0 {
+		return markJudgeErrors(db)
+	}
+	return nil
+}
+
+// Marks the submissions with dead jobs as "Judge Error", recording the error of their first dead job.
+// The tests of their dead run jobs get a Judge Error result.
+func markJudgeErrors(db db.DBContext) error {
+	if _, err := db.Exec(`INSERT OR IGNORE INTO test_results(submission_id, test_id, verdict, verdict_code, score, running_time, memory_used)
+		SELECT submission_id, test_id, ?, ?, 0, 0, 0 FROM jobs WHERE dead AND type = ?`,
+		VerdictJudgeError, VerdictCodeJudgeError, JobTypeRun); err != nil {
+		return errors.WithStack(err)
+	}
+	_, err := db.Exec(`UPDATE submissions SET verdict = ?, score = NULL, penalty = NULL, visible_score = NULL, visible_verdict = '',
+		judge_error = (SELECT j.last_error FROM jobs j WHERE j.dead AND j.submission_id = submissions.id ORDER BY j.id LIMIT 1)
+		WHERE verdict <> ? AND id IN (SELECT submission_id FROM jobs WHERE dead)`, VerdictJudgeError, VerdictJudgeError)
+	return errors.WithStack(err)
+}
+
+// Returns ErrJobLost if the query changed no rows, that is the job is not claimed by the job's claimer anymore.
+func claimedRows(res sql.Result, err error) error {
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if n == 0 {
+		return ErrJobLost
+	}
+	return nil
+}
+
+// ExtendLease extends the claim on the job.
+// Returns ErrJobLost if the job is not claimed by the job's claimer anymore: the work on it should be abandoned.
+func (r *Job) ExtendLease(db db.DBContext) error {
+	expiresAt := sql.NullTime{Time: time.Now().Add(JobLeaseDuration), Valid: true}
+	if err := claimedRows(db.Exec("UPDATE jobs SET claim_expires_at = ? WHERE id = ? AND claimed_by = ?",
+		expiresAt, r.ID, r.ClaimedBy)); err != nil {
+		return err
+	}
+	r.ClaimExpiresAt = expiresAt
+	return nil
+}
+
+// Finish removes the done job from the queue.
+// Returns ErrJobLost if the job is not claimed by the job's claimer anymore, in which case its results should be thrown away.
+func (r *Job) Finish(db db.DBContext) error {
+	return claimedRows(db.Exec("DELETE FROM jobs WHERE id = ? AND claimed_by = ?", r.ID, r.ClaimedBy))
+}
+
+// Fail records a failed attempt on the job, releasing its claim.
+// The job is retried later with an exponential backoff, or becomes dead when it ran out of attempts,
+// giving its submission the "Judge Error" verdict.
+// Returns ErrJobLost if the job is not claimed by the job's claimer anymore.
+func (r *Job) Fail(db db.DBContext, cause error) error {
+	dead := r.Attempts >= MaxJobAttempts
+	runAfter := sql.NullTime{Time: time.Now().Add(5 * time.Second << (r.Attempts - 1)), Valid: true}
+	if err := claimedRows(db.Exec(`UPDATE jobs SET claimed_by = NULL, claim_expires_at = NULL, last_error = ?, dead = ?, run_after = ?
+		WHERE id = ? AND claimed_by = ?`, cause.Error(), dead, runAfter, r.ID, r.ClaimedBy)); err != nil {
+		return err
+	}
+	r.ClaimedBy = sql.NullString{}
+	r.ClaimExpiresAt = sql.NullTime{}
+	r.LastError = cause.Error()
+	r.Dead = dead
+	r.RunAfter = runAfter
+	if r.Dead {
+		return markJudgeErrors(db)
+	}
+	return nil
+}
+
+// Status returns a human-readable status of the job.
+func (r *Job) Status() string {
+	switch {
+	case r.Dead:
+		return "dead"
+	case r.ClaimedBy.Valid:
+		return "running"
+	case r.RunAfter.Valid && r.RunAfter.Time.After(time.Now()):
+		return "waiting to retry"
+	default:
+		return "queued"
+	}
+}
+
+// CancelGroupRunJobs removes the Run jobs of the submission on the tests of the test group
+// that no worker has claimed yet.
+func CancelGroupRunJobs(db db.DBContext, subID, testGroupID int) error {
+	_, err := db.Exec(`DELETE FROM jobs WHERE type = ? AND submission_id = ? AND claimed_by IS NULL AND NOT dead
+		AND test_id IN (SELECT id FROM tests WHERE test_group_id = ?)`, JobTypeRun, subID, testGroupID)
+	return errors.WithStack(err)
+}
+
+// RetryJobs brings dead jobs back to the queue, with all their attempts restored.
+// Their submissions are judged again, unless they have other dead jobs.
+func RetryJobs(db db.DBContext, id ...int) error {
+	if len(id) == 0 {
+		return nil
+	}
+	query, args, err := sqlx.In("UPDATE jobs SET dead = 0, attempts = 0, run_after = NULL WHERE dead AND id IN (?)", id)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if _, err := db.Exec(query, args...); err != nil {
+		return errors.WithStack(err)
+	}
+	query, args, err = sqlx.In(`UPDATE submissions SET verdict = ?, judge_error = '' WHERE verdict = ?
+		AND id IN (SELECT submission_id FROM jobs WHERE id IN (?))
+		AND id NOT IN (SELECT submission_id FROM jobs WHERE dead)`, VerdictIsInQueue, VerdictJudgeError, id)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if _, err := db.Exec(query, args...); err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+// GetDeadJobs returns all the dead jobs.
+func GetDeadJobs(db db.DBContext) ([]*Job, error) {
+	var jobs []*Job
+	if err := db.Select(&jobs, "SELECT * FROM jobs WHERE dead"+queryJobOrderBy); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return jobs, nil
+}
+
 // BatchInsertJobs try to insert all given jobs.
 func BatchInsertJobs(db db.DBContext, jobs ...*Job) error {
 	if len(jobs) == 0 {
@@ -125,6 +295,9 @@ type QueueOverview struct {
 	Compile int
 	Run     int
 	Score   int
+
+	// Dead jobs are not counted towards the other counts.
+	Dead int
 }
 
 // Total returns the sum of all queue counts.
@@ -137,13 +310,18 @@ func GetQueueOverview(db db.DBContext) (*QueueOverview, error) {
 	type Count struct {
 		Count int     `db:"count"`
 		Type  JobType `db:"type"`
+		Dead  bool    `db:"dead"`
 	}
 	var rows []*Count
-	if err := db.Select(&rows, "SELECT COUNT(id) AS \"count\", type FROM jobs GROUP BY type"); err != nil {
+	if err := db.Select(&rows, "SELECT COUNT(id) AS \"count\", type, dead FROM jobs GROUP BY type, dead"); err != nil {
 		return nil, errors.WithStack(err)
 	}
 	var q QueueOverview
 	for _, row := range rows {
+		if row.Dead {
+			q.Dead += row.Count
+			continue
+		}
 		switch row.Type {
 		case JobTypeCompile:
 			q.Compile = row.Count
@@ -0,0 +1,191 @@
+package models
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/natsukagami/kjudge/db"
+)
+
+// Creates a fresh database, with a problem of one test and the given number of submissions to it.
+func newJobsTestDB(t *testing.T, submissions int) (*db.DB, []*Submission, *Test) {
+	t.Helper()
+	d, err := db.New(filepath.Join(t.TempDir(), "kjudge.db"))
+	if err != nil {
+		t.Fatalf("creating database: %+v", err)
+	}
+	t.Cleanup(func() { d.Close() })
+	must := func(err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatalf("%+v", err)
+		}
+	}
+	contest := &Contest{Name: "c", StartTime: time.Now(), EndTime: time.Now().Add(time.Hour),
+		ContestType: ContestTypeWeighted, ScoreboardViewStatus: ScoreboardViewStatusPublic}
+	must(contest.Write(d))
+	problem := &Problem{ContestID: contest.ID, Name: "A", DisplayName: "A", TimeLimit: 1000, MemoryLimit: 262144,
+		ScoringMode: ScoringModeBest, PenaltyPolicy: PenaltyPolicyNone, ComparisonMode: ComparisonModeDiff,
+		FeedbackLevel: FeedbackLevelFull, OutputPattern: "output?.txt"}
+	must(problem.Write(d))
+	tg := &TestGroup{ProblemID: problem.ID, Name: "g", Score: 100, ScoringMode: TestScoringModeSum, Visibility: TestGroupVisibilityVisible}
+	must(tg.Write(d))
+	test := &Test{TestGroupID: tg.ID, Name: "1", Input: []byte("1\n"), Output: []byte("1\n")}
+	must(test.Write(d))
+	must((&User{ID: "u", Password: "x", DisplayName: "u"}).Write(d))
+	var subs []*Submission
+	for i := 0; i < submissions; i++ {
+		sub := &Submission{ProblemID: problem.ID, UserID: "u", SubmittedAt: time.Now(), Language: "g++",
+			Source: []byte("int main() {}"), Verdict: VerdictIsInQueue}
+		must(sub.Write(d))
+		subs = append(subs, sub)
+	}
+	return d, subs, test
+}
+
+func claimJob(t *testing.T, d *db.DB, owner string) *Job {
+	t.Helper()
+	job, err := ClaimJob(d, owner)
+	if err != nil {
+		t.Fatalf("claiming a job: %+v", err)
+	}
+	return job
+}
+
+func TestClaimJobOrder(t *testing.T) {
+	d, subs, test := newJobsTestDB(t, 2)
+	first, second := subs[0], subs[1]
+	if err := BatchInsertJobs(d,
+		NewJobScore(first.ID), NewJobRun(first.ID, test.ID), NewJobRun(first.ID, test.ID), NewJobCompile(first.ID),
+		NewJobCompile(second.ID),
+	); err != nil {
+		t.Fatalf("inserting jobs: %+v", err)
+	}
+	expect := func(job *Job, typ JobType, sub *Submission) {
+		t.Helper()
+		if job == nil {
+			t.Fatalf("expected a %s job of submission %d, got none", typ, sub.ID)
+		}
+		if job.Type != typ || job.SubmissionID != sub.ID {
+			t.Fatalf("expected a %s job of submission %d, got a %s job of submission %d", typ, sub.ID, job.Type, job.SubmissionID)
+		}
+	}
+	finish := func(job *Job) {
+		t.Helper()
+		if err := job.Finish(d); err != nil {
+			t.Fatalf("finishing job %d: %+v", job.ID, err)
+		}
+	}
+
+	// The earlier submission goes first, starting with its compile job.
+	compile := claimJob(t, d, "a")
+	expect(compile, JobTypeCompile, first)
+	// Nothing else of the submission runs alongside its compile job.
+	expect(claimJob(t, d, "b"), JobTypeCompile, second)
+	if job := claimJob(t, d, "c"); job != nil {
+		t.Fatalf("expected no ready jobs while compiling, got a %s job of submission %d", job.Type, job.SubmissionID)
+	}
+
+	// Run jobs of the same submission run alongside each other, but not the score job.
+	finish(compile)
+	run1, run2 := claimJob(t, d, "a"), claimJob(t, d, "c")
+	expect(run1, JobTypeRun, first)
+	expect(run2, JobTypeRun, first)
+	if job := claimJob(t, d, "d"); job != nil {
+		t.Fatalf("expected no ready jobs while running tests, got a %s job", job.Type)
+	}
+	finish(run1)
+	if job := claimJob(t, d, "d"); job != nil {
+		t.Fatalf("expected no ready jobs while a test is running, got a %s job", job.Type)
+	}
+	finish(run2)
+	score := claimJob(t, d, "d")
+	expect(score, JobTypeScore, first)
+	if score.Attempts != 1 || !score.ClaimedBy.Valid || score.ClaimedBy.String != "d" {
+		t.Errorf("expected the score job claimed once by d, got %d attempt(s) by %v", score.Attempts, score.ClaimedBy)
+	}
+}
+
+func TestJobLease(t *testing.T) {
+	d, subs, _ := newJobsTestDB(t, 1)
+	if err := NewJobCompile(subs[0].ID).Write(d); err != nil {
+		t.Fatalf("inserting job: %+v", err)
+	}
+	lost := claimJob(t, d, "a")
+	if err := lost.ExtendLease(d); err != nil {
+		t.Fatalf("extending the lease: %+v", err)
+	}
+	if job := claimJob(t, d, "b"); job != nil {
+		t.Fatalf("expected the claimed job not to be taken again")
+	}
+
+	// The worker stops responding: its lease expires, and the job goes to another worker.
+	if _, err := d.Exec("UPDATE jobs SET claim_expires_at = ?", time.Now().Add(-time.Second)); err != nil {
+		t.Fatalf("expiring the lease: %+v", err)
+	}
+	job := claimJob(t, d, "b")
+	if job == nil || job.ID != lost.ID {
+		t.Fatalf("expected job %d to be claimed again, got %v", lost.ID, job)
+	}
+	if job.Attempts != 2 || job.LastError == "" {
+		t.Errorf("expected the expired claim to count as a failed attempt, got %d attempt(s), error %q", job.Attempts, job.LastError)
+	}
+
+	// The first worker lost the job: it cannot touch it anymore.
+	if err := lost.ExtendLease(d); !errors.Is(err, ErrJobLost) {
+		t.Errorf("ExtendLease by the lost claimer: expected ErrJobLost, got %v", err)
+	}
+	if err := lost.Fail(d, errors.New("failed")); !errors.Is(err, ErrJobLost) {
+		t.Errorf("Fail by the lost claimer: expected ErrJobLost, got %v", err)
+	}
+	if err := lost.Finish(d); !errors.Is(err, ErrJobLost) {
+		t.Errorf("Finish by the lost claimer: expected ErrJobLost, got %v", err)
+	}
+	if err := job.Finish(d); err != nil {
+		t.Errorf("Finish by the claimer: %+v", err)
+	}
+}
+
+func TestJobFail(t *testing.T) {
+	d, subs, _ := newJobsTestDB(t, 1)
+	if err := NewJobCompile(subs[0].ID).Write(d); err != nil {
+		t.Fatalf("inserting job: %+v", err)
+	}
+	job := claimJob(t, d, "a")
+	if err := job.Fail(d, errors.New("sandbox failed")); err != nil {
+		t.Fatalf("failing the job: %+v", err)
+	}
+	if job.Dead || !job.RunAfter.Valid {
+		t.Fatalf("expected the job to be retried later, got dead=%v run_after=%v", job.Dead, job.RunAfter)
+	}
+	if other := claimJob(t, d, "b"); other != nil {
+		t.Fatalf("expected the failed job to wait before being retried")
+	}
+
+	// The last attempt kills the job, and the submission gets a Judge Error.
+	if _, err := d.Exec("UPDATE jobs SET run_after = NULL, attempts = ?", MaxJobAttempts-1); err != nil {
+		t.Fatalf("skipping attempts: %+v", err)
+	}
+	job = claimJob(t, d, "b")
+	if job == nil || job.Attempts != MaxJobAttempts {
+		t.Fatalf("expected the job to be retried for the last time, got %v", job)
+	}
+	if err := job.Fail(d, errors.New("sandbox failed again")); err != nil {
+		t.Fatalf("failing the job: %+v", err)
+	}
+	if !job.Dead {
+		t.Errorf("expected the job to be dead after %d attempts", MaxJobAttempts)
+	}
+	if other := claimJob(t, d, "c"); other != nil {
+		t.Errorf("expected the dead job not to be claimed")
+	}
+	sub, err := GetSubmission(d, subs[0].ID)
+	if err != nil {
+		t.Fatalf("getting the submission: %+v", err)
+	}
+	if sub.Verdict != VerdictJudgeError || sub.JudgeError != "sandbox failed again" {
+		t.Errorf("expected a Judge Error with the job's error, got verdict %q, error %q", sub.Verdict, sub.JudgeError)
+	}
+}
@@ -0,0 +1,360 @@
+package models
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/natsukagami/kjudge/embed"
+	"github.com/pkg/errors"
+)
+
+// LanguageOutputOnly is the language of the submissions to output-only problems, which are archives of the outputs
+// of the tests instead of code. It is not defined with the other languages, and cannot be compiled or run.
+const LanguageOutputOnly Language = "output_only"
+
+// The built-in language definitions, in the embedded content.
+const defaultLanguagesFile = "assets/languages.toml"
+
+// LanguageSpec defines a language submissions can be written in: how to recognize, compile and run it.
+// See embed/assets/languages.toml for the meaning of each field.
+type LanguageSpec struct {
+	ID            Language   `toml:"id"`
+	Name          string     `toml:"name"`
+	Extensions    []string   `toml:"extensions"`
+	Source        string     `toml:"source"`
+	Compile       [][]string `toml:"compile"`
+	Output        string     `toml:"output"`
+	Run           []string   `toml:"run"`
+	Version       []string   `toml:"version"`
+	Batch         string     `toml:"batch"`
+	GraderCompile [][]string `toml:"grader_compile"`
+	GraderOutput  string     `toml:"grader_output"`
+	Disabled      bool       `toml:"disabled"`
+	// The default adjustment of the limits, which problems can override.
+	LanguageLimits
+
+	installed bool
+}
+
+// Available returns whether submissions in the language are accepted.
+func (s *LanguageSpec) Available() bool {
+	return s.installed && !s.Disabled
+}
+
+// SupportsGraders returns whether the language can be linked with a grader.
+func (s *LanguageSpec) SupportsGraders() bool {
+	return len(s.GraderCompile) > 0
+}
+
+// Fills in the defaults and checks the definition.
+func (s *LanguageSpec) normalize() error {
+	if s.ID == "" || s.ID == LanguageOutputOnly || strings.Contains(string(s.ID), ",") {
+		return errors.Errorf("invalid language id %q", s.ID)
+	}
+	if s.Name == "" {
+		s.Name = string(s.ID)
+	}
+	if len(s.Extensions) == 0 {
+		return errors.New("extensions: must not be empty")
+	}
+	for _, ext := range s.Extensions {
+		if !strings.HasPrefix(ext, ".") {
+			return errors.Errorf("extensions: %q must start with a dot", ext)
+		}
+	}
+	if s.Source == "" {
+		return errors.New("source: must not be empty")
+	}
+	if s.Output == "" {
+		s.Output = s.Source
+	}
+	if len(s.Run) == 0 {
+		s.Run = []string{"code"}
+	}
+	if s.GraderOutput == "" {
+		s.GraderOutput = s.Output
+	}
+	if s.TimeMultiplier == 0 {
+		s.TimeMultiplier = 1
+	}
+	if s.MemoryMultiplier == 0 {
+		s.MemoryMultiplier = 1
+	}
+	if err := s.LanguageLimits.verify(); err != nil {
+		return err
+	}
+	for _, commands := range [][][]string{s.Compile, s.GraderCompile} {
+		for _, command := range commands {
+			if len(command) == 0 {
+				return errors.New("compile commands must not be empty")
+			}
+		}
+	}
+	return nil
+}
+
+// Runs the version probe of the language.
+func (s *LanguageSpec) probe() bool {
+	if len(s.Version) == 0 {
+		return true
+	}
+	return exec.Command(s.Version[0], s.Version[1:]...).Run() == nil
+}
+
+// The loaded language definitions, in order.
+var languages []*LanguageSpec
+
+func init() {
+	if err := LoadLanguages(""); err != nil {
+		log.Panicf("cannot load the built-in languages: %+v", err)
+	}
+}
+
+// LoadLanguages replaces the language definitions with the ones in the TOML file at `path`, probing whether each
+// language is installed. With an empty path, the built-in definitions are loaded.
+// It should be called before any judging happens.
+func LoadLanguages(path string) error {
+	var (
+		content []byte
+		err     error
+	)
+	if path == "" {
+		content, err = fs.ReadFile(embed.Content, defaultLanguagesFile)
+	} else {
+		content, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	specs, err := ParseLanguages(content)
+	if err != nil {
+		return errors.Wrapf(err, "file %s", path)
+	}
+	for _, s := range specs {
+		s.installed = s.probe()
+		if !s.installed {
+			log.Printf("\"%s\" seems to be unavailable on the system. Declining all submissions with the language...", s.ID)
+		}
+	}
+	languages = specs
+	return nil
+}
+
+// ParseLanguages parses the language definitions from the content of a TOML file.
+func ParseLanguages(content []byte) ([]*LanguageSpec, error) {
+	var file struct {
+		Languages []*LanguageSpec `toml:"language"`
+	}
+	if err := toml.Unmarshal(content, &file); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	ids := make(map[Language]bool)
+	exts := make(map[string]Language)
+	for i, s := range file.Languages {
+		if err := s.normalize(); err != nil {
+			return nil, errors.WithMessagef(err, "language #%d (%s)", i+1, s.ID)
+		}
+		if ids[s.ID] {
+			return nil, errors.Errorf("language %s is defined twice", s.ID)
+		}
+		ids[s.ID] = true
+		for _, ext := range s.Extensions {
+			if other, ok := exts[ext]; ok {
+				return nil, errors.Errorf("extension %s is used by both %s and %s", ext, other, s.ID)
+			}
+			exts[ext] = s.ID
+		}
+	}
+	return file.Languages, nil
+}
+
+// Languages returns all defined languages, including the unavailable ones.
+func Languages() []*LanguageSpec {
+	return languages
+}
+
+// AvailableLanguages returns the languages submissions are accepted in.
+func AvailableLanguages() []*LanguageSpec {
+	var list []*LanguageSpec
+	for _, s := range languages {
+		if s.Available() {
+			list = append(list, s)
+		}
+	}
+	return list
+}
+
+// Spec returns the definition of the language, or nil if it is not defined.
+func (l Language) Spec() *LanguageSpec {
+	for _, s := range languages {
+		if s.ID == l {
+			return s
+		}
+	}
+	return nil
+}
+
+// Name returns the display name of the language.
+func (l Language) Name() string {
+	if l == LanguageOutputOnly {
+		return "Output only"
+	}
+	if s := l.Spec(); s != nil {
+		return s.Name
+	}
+	return string(l)
+}
+
+// LanguageByExt returns a language based on the file extension.
+func LanguageByExt(ext string) (Language, error) {
+	for _, s := range languages {
+		for _, e := range s.Extensions {
+			if e == ext {
+				return s.ID, nil
+			}
+		}
+	}
+	return "", errors.New("unknown language")
+}
+
+// LanguageLimits adjusts the time and memory limits for submissions in a language:
+// the limits are multiplied by the multipliers, then the offsets are added.
+// Zero multipliers are taken as 1.
+type LanguageLimits struct {
+	TimeMultiplier   float64 `toml:"time_multiplier"`
+	TimeOffset       int     `toml:"time_offset"` // In milliseconds
+	MemoryMultiplier float64 `toml:"memory_multiplier"`
+	MemoryOffset     int     `toml:"memory_offset"` // In KBs
+}
+
+func (l LanguageLimits) verify() error {
+	if l.TimeMultiplier < 0 || l.MemoryMultiplier < 0 {
+		return errors.New("limit multipliers must be positive")
+	}
+	if l.TimeOffset < 0 || l.MemoryOffset < 0 {
+		return errors.New("limit offsets must not be negative")
+	}
+	return nil
+}
+
+// TimeLimit returns the adjusted time limit.
+func (l LanguageLimits) TimeLimit(limit time.Duration) time.Duration {
+	if l.TimeMultiplier != 0 {
+		limit = time.Duration(float64(limit) * l.TimeMultiplier)
+	}
+	return limit + time.Duration(l.TimeOffset)*time.Millisecond
+}
+
+// MemoryLimit returns the adjusted memory limit, in KBs.
+func (l LanguageLimits) MemoryLimit(limit int) int {
+	if l.MemoryMultiplier != 0 {
+		limit = int(float64(limit) * l.MemoryMultiplier)
+	}
+	return limit + l.MemoryOffset
+}
+
+// IsIdentity returns whether the limits are left as-is.
+func (l LanguageLimits) IsIdentity() bool {
+	return (l.TimeMultiplier == 0 || l.TimeMultiplier == 1) && l.TimeOffset == 0 &&
+		(l.MemoryMultiplier == 0 || l.MemoryMultiplier == 1) && l.MemoryOffset == 0
+}
+
+// String describes the adjustment, e.g. "time ×2 + 1000ms, memory ×1 + 0KBs".
+func (l LanguageLimits) String() string {
+	multiplier := func(m float64) float64 {
+		if m == 0 {
+			return 1
+		}
+		return m
+	}
+	return fmt.Sprintf("time ×%g + %dms, memory ×%g + %dKBs",
+		multiplier(l.TimeMultiplier), l.TimeOffset, multiplier(l.MemoryMultiplier), l.MemoryOffset)
+}
+
+// LanguageSet is a set of languages, stored as a comma-separated list.
+// An empty set does not restrict the languages at all.
+type LanguageSet []Language
+
+// Allows returns whether the set allows the language.
+func (ls LanguageSet) Allows(l Language) bool {
+	if len(ls) == 0 {
+		return true
+	}
+	for _, item := range ls {
+		if item == l {
+			return true
+		}
+	}
+	return false
+}
+
+// Filter returns the languages in `specs` allowed by the set.
+func (ls LanguageSet) Filter(specs []*LanguageSpec) []*LanguageSpec {
+	var list []*LanguageSpec
+	for _, s := range specs {
+		if ls.Allows(s.ID) {
+			list = append(list, s)
+		}
+	}
+	return list
+}
+
+// Languages missing from the registry are allowed (and ignored by Filter), so that removing a language from the
+// registry does not make the contests and problems listing it unwritable.
+func (ls LanguageSet) verify() error {
+	for _, l := range ls {
+		if l == "" || strings.ContainsAny(string(l), ", ") {
+			return errors.Errorf("invalid language id %q", l)
+		}
+	}
+	return nil
+}
+
+// Value implements driver.Valuer.
+func (ls LanguageSet) Value() (driver.Value, error) {
+	items := make([]string, len(ls))
+	for i, l := range ls {
+		items[i] = string(l)
+	}
+	return strings.Join(items, ","), nil
+}
+
+// Scan implements sql.Scanner.
+func (ls *LanguageSet) Scan(src interface{}) error {
+	var value string
+	switch v := src.(type) {
+	case string:
+		value = v
+	case []byte:
+		value = string(v)
+	case nil:
+	default:
+		return errors.Errorf("cannot scan %T into a language set", src)
+	}
+	*ls = nil
+	for _, item := range strings.Split(value, ",") {
+		if item != "" {
+			*ls = append(*ls, Language(item))
+		}
+	}
+	return nil
+}
+
+// String returns the display names of the languages in the set.
+func (ls LanguageSet) String() string {
+	if len(ls) == 0 {
+		return "All languages"
+	}
+	names := make([]string, len(ls))
+	for i, l := range ls {
+		names[i] = l.Name()
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,133 @@
+package models
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/natsukagami/kjudge/models/verify"
+	"github.com/pkg/errors"
+)
+
+// ExpectedOutcome is the outcome a model solution is expected to get when judged. There are:
+// - Accepted: The solution passes every test.
+// - WrongAnswer: The solution gets a wrong answer on some test, and passes the others.
+// - TimeLimit: The solution runs out of time on some test, and passes the others.
+// - Scores: The solution gets the given score on each test group (see ExpectedScores).
+type ExpectedOutcome string
+
+// All possible values of ExpectedOutcome.
+const (
+	ExpectedOutcomeAccepted    ExpectedOutcome = "accepted"
+	ExpectedOutcomeWrongAnswer ExpectedOutcome = "wrong_answer"
+	ExpectedOutcomeTimeLimit   ExpectedOutcome = "time_limit"
+	ExpectedOutcomeScores      ExpectedOutcome = "scores"
+)
+
+func (e ExpectedOutcome) verify() error {
+	return verify.String(string(e), verify.Enum(string(ExpectedOutcomeAccepted), string(ExpectedOutcomeWrongAnswer),
+		string(ExpectedOutcomeTimeLimit), string(ExpectedOutcomeScores)))
+}
+
+// ExpectedScores are the scores a model solution is expected to get on the test groups, keyed by their names.
+// They are stored (and written in forms) as a comma-separated list of "name=score". Test groups not in the list
+// are not checked.
+type ExpectedScores map[string]float64
+
+// ParseExpectedScores parses a comma-separated list of "name=score".
+func ParseExpectedScores(s string) (ExpectedScores, error) {
+	res := make(ExpectedScores)
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		parts := strings.SplitN(item, "=", 2)
+		if len(parts) != 2 {
+			return nil, errors.Errorf("%q: expected name=score", item)
+		}
+		name := strings.TrimSpace(parts[0])
+		score, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+		if err != nil {
+			return nil, errors.Errorf("%q: invalid score", item)
+		}
+		if _, ok := res[name]; ok {
+			return nil, errors.Errorf("test group %s is given twice", name)
+		}
+		res[name] = score
+	}
+	return res, nil
+}
+
+// String returns the scores as a comma-separated list of "name=score", sorted by name.
+func (e ExpectedScores) String() string {
+	names := make([]string, 0, len(e))
+	for name := range e {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	items := make([]string, len(names))
+	for i, name := range names {
+		items[i] = fmt.Sprintf("%s=%s", name, strconv.FormatFloat(e[name], 'f', -1, 64))
+	}
+	return strings.Join(items, ", ")
+}
+
+// Matches returns whether the score of the test group matches the expected one, if there is any.
+func (e ExpectedScores) Matches(testGroup string, score float64) bool {
+	expected, ok := e[testGroup]
+	return !ok || math.Abs(expected-score) < 1e-6
+}
+
+// Value implements driver.Valuer.
+func (e ExpectedScores) Value() (driver.Value, error) {
+	return e.String(), nil
+}
+
+// Scan implements sql.Scanner.
+func (e *ExpectedScores) Scan(src interface{}) error {
+	var value string
+	switch v := src.(type) {
+	case string:
+		value = v
+	case []byte:
+		value = string(v)
+	case nil:
+	default:
+		return errors.Errorf("cannot scan %T into expected scores", src)
+	}
+	scores, err := ParseExpectedScores(value)
+	if err != nil {
+		return err
+	}
+	*e = scores
+	return nil
+}
+
+// Verify verifies ModelSolution's content.
+func (r *ModelSolution) Verify() error {
+	if r.Source == nil {
+		return errors.New("source must not be null")
+	}
+	var language, expectedScores error
+	if r.Language.Spec() == nil {
+		language = errors.Errorf("unknown language %s", r.Language)
+	}
+	if r.Expected == ExpectedOutcomeScores && len(r.ExpectedScores) == 0 {
+		expectedScores = errors.New("must give the score of at least one test group")
+	}
+	for name, score := range r.ExpectedScores {
+		if score < 0 {
+			expectedScores = errors.Errorf("the score of %s must not be negative", name)
+		}
+	}
+	return verify.All(map[string]error{
+		"Name":           verify.Names(r.Name),
+		"Language":       language,
+		"Expected":       r.Expected.verify(),
+		"ExpectedScores": expectedScores,
+	})
+}
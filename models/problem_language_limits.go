@@ -0,0 +1,68 @@
+package models
+
+import (
+	"github.com/natsukagami/kjudge/db"
+	"github.com/natsukagami/kjudge/models/verify"
+	"github.com/pkg/errors"
+)
+
+// Verify verifies the content of the ProblemLanguageLimit.
+func (r *ProblemLanguageLimit) Verify() error {
+	var language error
+	if r.Language.Spec() == nil {
+		language = errors.Errorf("unknown language %s", r.Language)
+	}
+	return verify.All(map[string]error{
+		"Language":         language,
+		"TimeMultiplier":   verify.Float(r.TimeMultiplier, verify.FloatMin(0.01)),
+		"TimeOffset":       verify.IntMin(0)(r.TimeOffset),
+		"MemoryMultiplier": verify.Float(r.MemoryMultiplier, verify.FloatMin(0.01)),
+		"MemoryOffset":     verify.IntMin(0)(r.MemoryOffset),
+	})
+}
+
+// Limits returns the limits adjustment of the override.
+func (r *ProblemLanguageLimit) Limits() LanguageLimits {
+	return LanguageLimits{
+		TimeMultiplier:   r.TimeMultiplier,
+		TimeOffset:       r.TimeOffset,
+		MemoryMultiplier: r.MemoryMultiplier,
+		MemoryOffset:     r.MemoryOffset,
+	}
+}
+
+// LanguageLimitsOf returns the limits adjustment of the language, taking the problem's overrides
+// into account.
+func LanguageLimitsOf(l Language, overrides []*ProblemLanguageLimit) LanguageLimits {
+	for _, o := range overrides {
+		if o.Language == l {
+			return o.Limits()
+		}
+	}
+	if s := l.Spec(); s != nil {
+		return s.LanguageLimits
+	}
+	return LanguageLimits{}
+}
+
+// GetLanguageLimits returns the limits adjustment of the language on the problem.
+func GetLanguageLimits(db db.DBContext, problemID int, l Language) (LanguageLimits, error) {
+	overrides, err := GetProblemProblemLanguageLimits(db, problemID)
+	if err != nil {
+		return LanguageLimits{}, err
+	}
+	return LanguageLimitsOf(l, overrides), nil
+}
+
+// WriteLanguageLimit writes the override of the problem's limits for its language, replacing the existing one.
+func (r *Problem) WriteLanguageLimit(db db.DBContext, limit *ProblemLanguageLimit) error {
+	limit.ProblemID = r.ID
+	if err := limit.Verify(); err != nil {
+		return err
+	}
+	if _, err := db.Exec("DELETE FROM problem_language_limits WHERE problem_id = ? AND language = ?", r.ID, limit.Language); err != nil {
+		return errors.WithStack(err)
+	}
+	limit.ID = 0
+	return limit.Write(db)
+}
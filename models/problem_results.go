@@ -16,9 +16,33 @@ func (r *ProblemResult) Verify() error {
 		"Penalty":        verify.Int(r.Penalty, verify.IntMin(0)),
 		"Score":          verify.Float(r.Score, verify.FloatMin(0)),
 		"FailedAttempts": verify.Int(r.FailedAttempts, verify.IntMin(0)),
+		"VisibleScore":   verify.Float(r.VisibleScore, verify.FloatMin(0)),
 	})
 }
 
+// DuringContest returns the result as the contestants see it until the contest ends: computed from the scores
+// of the submissions on the test groups visible during the contest (see Submission.DuringContest).
+func (r *ProblemResult) DuringContest() *ProblemResult {
+	return &ProblemResult{
+		UserID:           r.UserID,
+		ProblemID:        r.ProblemID,
+		Solved:           r.VisibleSolved,
+		Score:            r.VisibleScore,
+		Penalty:          r.VisiblePenalty,
+		BestSubmissionID: r.VisibleBestSubmissionID,
+		FailedAttempts:   r.VisibleFailedAttempts,
+	}
+}
+
+// SetDuringContest records `visible` as the result the contestants see until the contest ends.
+func (r *ProblemResult) SetDuringContest(visible *ProblemResult) {
+	r.VisibleSolved = visible.Solved
+	r.VisibleScore = visible.Score
+	r.VisiblePenalty = visible.Penalty
+	r.VisibleBestSubmissionID = visible.BestSubmissionID
+	r.VisibleFailedAttempts = visible.FailedAttempts
+}
+
 // CollectProblemResults collects an user's problem results for a contest.
 // The result map's key is the problem ID.
 func CollectUserProblemResults(db db.DBContext, userID string, problems []*Problem) (map[int]*ProblemResult, error) {
@@ -2,7 +2,9 @@ package models
 
 import (
 	"fmt"
+	"regexp"
 	"strings"
+	"time"
 
 	"github.com/jmoiron/sqlx"
 	"github.com/natsukagami/kjudge/db"
@@ -51,6 +53,64 @@ func (p PenaltyPolicy) verify() error {
 	return verify.String(string(p), verify.Enum(string(PenaltyPolicyNone), string(PenaltyPolicySubmitTime), string(PenaltyPolicyICPC)))
 }
 
+// ComparisonMode dictates how the submission's output is compared with the expected output,
+// when the problem has no custom comparator or checker.
+// There are:
+// - Diff: The outputs are compared with "diff", ignoring whitespace.
+// - Exact: The outputs must be byte-for-byte equal.
+// - Tokens: The outputs must have the same whitespace-separated tokens.
+// - Lines: The outputs must have the same lines, ignoring trailing whitespace and trailing empty lines.
+// - TokensCaseInsensitive: Same as Tokens, but letter cases are ignored.
+// - Numeric: Same as Tokens, but tokens that are numbers only need to be within the absolute or relative
+// epsilon of the expected number.
+type ComparisonMode string
+
+// Defined values for ComparisonMode.
+const (
+	ComparisonModeDiff                  ComparisonMode = "diff"
+	ComparisonModeExact                 ComparisonMode = "exact"
+	ComparisonModeTokens                ComparisonMode = "tokens"
+	ComparisonModeLines                 ComparisonMode = "lines"
+	ComparisonModeTokensCaseInsensitive ComparisonMode = "tokens_case_insensitive"
+	ComparisonModeNumeric               ComparisonMode = "numeric"
+)
+
+func (c ComparisonMode) verify() error {
+	return verify.String(string(c), verify.Enum(string(ComparisonModeDiff), string(ComparisonModeExact), string(ComparisonModeTokens),
+		string(ComparisonModeLines), string(ComparisonModeTokensCaseInsensitive), string(ComparisonModeNumeric)))
+}
+
+// FeedbackLevel dictates how much of the judging results the contestants see for their submissions.
+// There are:
+// - Full: The result of every test.
+// - Groups: The score of each test group, without the tests' results.
+// - FirstFailure: The verdict of the first test that is not accepted only (ICPC-like).
+// - Verdict: The submission's verdict and score only.
+// - None: Nothing but whether the submission compiled.
+type FeedbackLevel string
+
+// Defined values for FeedbackLevel.
+const (
+	FeedbackLevelFull         FeedbackLevel = "full"
+	FeedbackLevelGroups       FeedbackLevel = "groups"
+	FeedbackLevelFirstFailure FeedbackLevel = "first_failure"
+	FeedbackLevelVerdict      FeedbackLevel = "verdict"
+	FeedbackLevelNone         FeedbackLevel = "none"
+)
+
+func (f FeedbackLevel) verify() error {
+	return verify.String(string(f), verify.Enum(string(FeedbackLevelFull), string(FeedbackLevelGroups), string(FeedbackLevelFirstFailure),
+		string(FeedbackLevelVerdict), string(FeedbackLevelNone)))
+}
+
+// ContestantFeedback returns the feedback level of the problem's submissions, which is full once the contest has ended.
+func (r *Problem) ContestantFeedback(contest *Contest) FeedbackLevel {
+	if contest.EndTime.Before(time.Now()) {
+		return FeedbackLevelFull
+	}
+	return r.FeedbackLevel
+}
+
 // Verify verifies a Problem's content.
 func (r *Problem) Verify() error {
 	return verify.All(map[string]error{
@@ -62,9 +122,51 @@ func (r *Problem) Verify() error {
 		"MemoryLimit":               verify.IntPositive(r.MemoryLimit),
 		"MaxSubmissionsCount":       verify.IntMin(0)(r.MaxSubmissionsCount),
 		"SecondsBetweenSubmissions": verify.IntMin(0)(r.SecondsBetweenSubmissions),
+		"Languages":                 r.Languages.verify(),
+		"ComparisonMode":            r.ComparisonMode.verify(),
+		"ComparisonAbsEpsilon":      verify.Float(r.ComparisonAbsEpsilon, verify.FloatMin(0)),
+		"ComparisonRelEpsilon":      verify.Float(r.ComparisonRelEpsilon, verify.FloatMin(0)),
+		"FeedbackLevel":             r.FeedbackLevel.verify(),
+		"OutputPattern":             r.verifyOutputPattern(),
+		"InputFile":                 verify.String(r.InputFile, verify.StringEmptyOr(verifyIOFilename)),
+		"OutputFile":                verify.String(r.OutputFile, verify.StringEmptyOr(verifyIOFilename), r.verifyOutputFile),
 	})
 }
 
+// The file names allowed for file-based I/O: plain file names, inside the submission's working directory.
+var ioFilenameRegexp = regexp.MustCompile(`[A-Za-z0-9_][A-Za-z0-9_.\-]*`)
+
+func verifyIOFilename(s string) error {
+	if s == "code" {
+		return errors.New("is reserved for the submission itself")
+	}
+	return verify.String(s, verify.StringMaxLength(64), verify.Regexp(ioFilenameRegexp))
+}
+
+func (r *Problem) verifyOutputFile(s string) error {
+	if s != "" && s == r.InputFile {
+		return errors.New("must be different from the input file")
+	}
+	return nil
+}
+
+// FileInput returns whether the problem's submissions read their input from a file.
+func (r *Problem) FileInput() bool {
+	return r.InputFile != ""
+}
+
+// FileOutput returns whether the problem's submissions write their output to a file.
+func (r *Problem) FileOutput() bool {
+	return r.OutputFile != ""
+}
+
+func (r *Problem) verifyOutputPattern() error {
+	if r.OutputOnly && strings.Count(r.OutputPattern, "?") != 1 {
+		return errors.New("must have exactly one question mark, standing for the test's name")
+	}
+	return nil
+}
+
 // AdminLink is the link to the problem in the admin panel.
 func (r *Problem) AdminLink() string {
 	return fmt.Sprintf("/admin/problems/%d", r.ID)
@@ -86,32 +188,30 @@ type ProblemWithTestGroups struct {
 func (p *ProblemWithTestGroups) TotalScore() float64 {
 	total := 0.0
 	for _, tg := range p.TestGroups {
-		if tg.Score >= 0 {
-			total += tg.Score
-		}
+		total += tg.Score
 	}
 	return total
 }
 
 // SubtaskScores returns the problem's test group scores as a list seperated by forward slash.
-func (p *ProblemWithTestGroups) SubtaskScores() string {
-	first := true
+// The scores of test groups the contestants do not see (given whether the contest has ended) are shown as "?".
+func (p *ProblemWithTestGroups) SubtaskScores(contestEnded bool) string {
 	var res strings.Builder
-	for _, tg := range p.TestGroups {
-		if tg.Score < 0 {
-			continue
-		}
-		if !first {
+	for i, tg := range p.TestGroups {
+		if i > 0 {
 			res.WriteString("/")
 		}
-		first = false
-		res.WriteString(fmt.Sprintf("%.2f", tg.Score))
+		if tg.VisibleTo(contestEnded) {
+			res.WriteString(fmt.Sprintf("%.2f", tg.Score))
+		} else {
+			res.WriteString("?")
+		}
 	}
 	return res.String()
 }
 
 // CollectTestGroups collects the test groups for a list of problems.
-func CollectTestGroups(db db.DBContext, problems []*Problem, private bool) ([]*ProblemWithTestGroups, error) {
+func CollectTestGroups(db db.DBContext, problems []*Problem) ([]*ProblemWithTestGroups, error) {
 	if len(problems) == 0 {
 		return nil, nil
 	}
@@ -121,12 +221,8 @@ func CollectTestGroups(db db.DBContext, problems []*Problem, private bool) ([]*P
 		pMap[p.ID] = &ProblemWithTestGroups{Problem: p}
 		IDs = append(IDs, p.ID)
 	}
-	privateQuery := ""
-	if !private {
-		privateQuery = " AND score >= 0"
-	}
 	var tgs []*TestGroup
-	query, args, err := sqlx.In("SELECT * FROM test_groups WHERE problem_id IN (?)"+privateQuery+queryTestGroupOrderBy, IDs)
+	query, args, err := sqlx.In("SELECT * FROM test_groups WHERE problem_id IN (?)"+queryTestGroupOrderBy, IDs)
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
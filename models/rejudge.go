@@ -18,9 +18,16 @@ func batchScoreJobs(subIDs ...int) []*Job {
 	return jobs
 }
 
-// Remove the submission's `score`, `penalty` and `verdict`.
+// Remove the submission's `score`, `penalty`, `verdict` (along with the visible ones) and judge error, along with their dead jobs.
 func resetScore(db db.DBContext, subIDs ...int) error {
-	query, params, err := sqlx.In(`UPDATE submissions SET score = NULL, penalty = NULL, verdict =  ? WHERE id IN (?)`, VerdictIsInQueue, subIDs)
+	query, params, err := sqlx.In(`UPDATE submissions SET score = NULL, penalty = NULL, verdict =  ?, visible_score = NULL, visible_verdict = '', judge_error = '' WHERE id IN (?)`, VerdictIsInQueue, subIDs)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if _, err := db.Exec(query, params...); err != nil {
+		return errors.WithStack(err)
+	}
+	query, params, err = sqlx.In(`DELETE FROM jobs WHERE dead AND submission_id IN (?)`, subIDs)
 	if err != nil {
 		return errors.WithStack(err)
 	}
@@ -46,10 +46,10 @@ type JSONUserResult struct {
 	ProblemResults map[int]JSONProblemResult `json:"problem_results"`
 }
 
-func jsonUserResult(u *UserResult, ps []JSONProblem) JSONUserResult {
+func jsonUserResult(u *UserResult, ps []JSONProblem, hidden map[int]bool) JSONUserResult {
 	problems := make(map[int]JSONProblemResult)
 	for _, p := range ps {
-		problems[p.ID] = jsonProblemResult(u.ProblemResults[p.ID])
+		problems[p.ID] = jsonProblemResult(u.ProblemResults[p.ID], hidden[p.ID])
 	}
 	return JSONUserResult{
 		ID:             u.User.ID,
@@ -70,12 +70,17 @@ type JSONProblemResult struct {
 	Penalty        int     `json:"penalty"`
 	FailedAttempts int     `json:"failed_attempts"`
 	BestSubmission int64   `json:"best_submission"`
+	// Whether the user has submitted to a problem whose results are hidden (see Scoreboard.HiddenProblems).
+	Hidden bool `json:"hidden,omitempty"`
 }
 
-func jsonProblemResult(p *ProblemResult) JSONProblemResult {
+func jsonProblemResult(p *ProblemResult, hidden bool) JSONProblemResult {
 	if p == nil {
 		return JSONProblemResult{}
 	}
+	if hidden {
+		return JSONProblemResult{BestSubmission: -1, Hidden: true}
+	}
 
 	var bestSubmission int64
 	if p.BestSubmissionID.Valid {
@@ -113,6 +118,9 @@ type Scoreboard struct {
 	Problems            []*Problem
 	UserResults         []*UserResult
 	ProblemFirstSolvers map[int]int64
+	// The problems whose results are hidden: they do not count towards the totals and the ranking,
+	// and their cells only show whether the user has submitted.
+	HiddenProblems map[int]bool
 }
 
 // JSON returns the JSON representation of the scoreboard.
@@ -126,7 +134,7 @@ func (s *Scoreboard) JSON() JSONScoreboard {
 		sb.Problems = append(sb.Problems, jsonProblem(p))
 	}
 	for _, u := range s.UserResults {
-		sb.Users = append(sb.Users, jsonUserResult(u, sb.Problems))
+		sb.Users = append(sb.Users, jsonUserResult(u, sb.Problems, s.HiddenProblems))
 	}
 	return sb
 }
@@ -159,8 +167,27 @@ func compareUserRanking(userResult []*UserResult, contestType ContestType, i, j
 	return true, true
 }
 
+// GetContestantScoreboard returns the scoreboard as the contestants see it.
+// Until the contest ends, the results are the ones on the test groups visible during the contest
+// (see ProblemResult.DuringContest), and the results of the problems without feedback (see FeedbackLevelNone) are hidden.
+func GetContestantScoreboard(db db.DBContext, contest *Contest, problems []*Problem) (*Scoreboard, error) {
+	hidden := make(map[int]bool)
+	for _, p := range problems {
+		if p.ContestantFeedback(contest) == FeedbackLevelNone {
+			hidden[p.ID] = true
+		}
+	}
+	return getScoreboard(db, contest, problems, !contest.EndTime.Before(time.Now()), hidden)
+}
+
 // Get scoreboard given problems and contest
 func GetScoreboard(db db.DBContext, contest *Contest, problems []*Problem) (*Scoreboard, error) {
+	return getScoreboard(db, contest, problems, false, nil)
+}
+
+// Get the scoreboard, with the results during the contest if `duringContest`,
+// and with the results of the problems in `hidden` hidden.
+func getScoreboard(db db.DBContext, contest *Contest, problems []*Problem, duringContest bool, hidden map[int]bool) (*Scoreboard, error) {
 	// If the contest has not started, throw
 	if contest.StartTime.After(time.Now()) {
 		return nil, httperr.BadRequestf("Contest has not started")
@@ -188,9 +215,17 @@ func GetScoreboard(db db.DBContext, contest *Contest, problems []*Problem) (*Sco
 		}
 	}
 	for _, problemResult := range contestProblemResults {
+		if duringContest {
+			problemResult = problemResult.DuringContest()
+		}
 		userID := problemResult.UserID
 		problemID := problemResult.ProblemID
 
+		if hidden[problemID] {
+			// Only keep that the user has submitted.
+			userProblemResults[userID].ProblemResults[problemID] = &ProblemResult{UserID: userID, ProblemID: problemID}
+			continue
+		}
 		userProblemResults[userID].TotalScore += problemResult.Score
 		userProblemResults[userID].TotalPenalty += problemResult.Penalty
 		if problemResult.Solved {
@@ -219,8 +254,8 @@ func GetScoreboard(db db.DBContext, contest *Contest, problems []*Problem) (*Sco
 		problemResults := userProblemResult.ProblemResults
 		for _, problemResult := range problemResults {
 			problemID := problemResult.ProblemID
-			// skip the problemResult with verdict != Solved
-			if !problemResult.Solved {
+			// skip the problemResult with verdict != Solved, or hidden
+			if !problemResult.Solved || hidden[problemID] {
 				continue
 			}
 			// skip if there is no submission
@@ -256,6 +291,7 @@ func GetScoreboard(db db.DBContext, contest *Contest, problems []*Problem) (*Sco
 		Problems:            problems,
 		UserResults:         userResults,
 		ProblemFirstSolvers: problemFirstSolvers,
+		HiddenProblems:      hidden,
 	}, nil
 }
 
@@ -274,7 +310,9 @@ func (s *Scoreboard) CSVScoresOnly(w io.Writer) error {
 	for _, u := range s.UserResults {
 		row := []string{u.User.ID, u.User.DisplayName, u.User.Organization, fmt.Sprintf("%.2f", u.TotalScore)}
 		for _, p := range s.Problems {
-			if score, ok := u.ProblemResults[p.ID]; ok {
+			if score, ok := u.ProblemResults[p.ID]; ok && s.HiddenProblems[p.ID] {
+				row = append(row, "?")
+			} else if ok {
 				row = append(row, fmt.Sprintf("%.2f", score.Score))
 			} else {
 				row = append(row, "-")
@@ -303,7 +341,9 @@ func (s *Scoreboard) CSV(w io.Writer) error {
 	for _, u := range s.UserResults {
 		row := []string{u.User.ID, u.User.DisplayName, u.User.Organization, fmt.Sprintf("%.2f", u.TotalScore), fmt.Sprint(u.TotalPenalty)}
 		for _, p := range s.Problems {
-			if score, ok := u.ProblemResults[p.ID]; ok {
+			if score, ok := u.ProblemResults[p.ID]; ok && s.HiddenProblems[p.ID] {
+				row = append(row, "?", "?")
+			} else if ok {
 				row = append(row, fmt.Sprintf("%.2f", score.Score), fmt.Sprint(score.Penalty))
 			} else {
 				row = append(row, "-", "-")
@@ -1,8 +1,8 @@
 package models
 
 import (
-	"log"
-	"os/exec"
+	"database/sql"
+	"fmt"
 
 	"github.com/jmoiron/sqlx"
 	"github.com/natsukagami/kjudge/db"
@@ -10,69 +10,32 @@ import (
 	"github.com/pkg/errors"
 )
 
-// Language represents the language of the submission.
+// Language represents the language of the submission, as the ID of its definition (see LanguageSpec).
 // The available values depend on the machine the judge is run on.
 type Language string
 
-const (
-	LanguageCpp  Language = "g++"
-	LanguagePas  Language = "fpc"
-	LanguageJava Language = "javac"
-	LanguagePy2  Language = "python2"
-	LanguagePy3  Language = "python3"
-	LanguageGo   Language = "go"
-	LanguageRust Language = "rustc"
-)
-
 const (
 	VerdictCompileError = "Compile Error"
 	VerdictScored       = "Scored"
 	VerdictAccepted     = "Accepted"
 	VerdictIsInQueue    = "..."
+	// Submissions the judge failed on (e.g. because of a broken checker or sandbox).
+	// Their internal error is kept in JudgeError, and they do not count towards the results.
+	VerdictJudgeError = "Judge Error"
+	// Shown to the contestants in place of the verdict of judged submissions, on problems without feedback.
+	VerdictJudged = "Judged"
 )
 
-var availableLanguages []string
-
-// LanguageByExt returns a language based on the file extension.
-func LanguageByExt(ext string) (Language, error) {
-	switch ext {
-	case ".cpp", ".cc":
-		return LanguageCpp, nil
-	case ".pas":
-		return LanguagePas, nil
-	case ".java":
-		return LanguageJava, nil
-	case ".py2":
-		return LanguagePy2, nil
-	case ".py3", ".py":
-		return LanguagePy3, nil
-	case ".go":
-		return LanguageGo, nil
-	case ".rs":
-		return LanguageRust, nil
-	default:
-		return "", errors.New("unknown language")
+// Only the language's existence is checked: submissions in languages that were disabled or uninstalled since
+// must stay judgeable. New submissions are checked against the available languages when they are submitted.
+func (l Language) verify() error {
+	if l == LanguageOutputOnly {
+		return nil
 	}
-}
-
-func init() {
-	for _, l := range []Language{LanguageCpp, LanguagePas, LanguageJava, LanguagePy2, LanguagePy3, LanguageGo, LanguageRust} {
-		ok := false
-		for _, versionArg := range []string{"--version", "-version", "version", "-iW"} {
-			if exec.Command(string(l), versionArg).Run() == nil {
-				availableLanguages = append(availableLanguages, string(l))
-				ok = true
-				break
-			}
-		}
-		if !ok {
-			log.Printf("\"%s\" seems to be unavailable on the system. Declining all submissions with the language...", l)
-		}
+	if l.Spec() == nil {
+		return errors.Errorf("unknown language %s", l)
 	}
-}
-
-func (l Language) verify() error {
-	return verify.String(string(l), verify.Enum(availableLanguages...))
+	return nil
 }
 
 // Verify verifies Submission's content.
@@ -94,6 +57,41 @@ func (r *Submission) Verify() error {
 	return verify.All(m)
 }
 
+// WithFeedback returns the submission as the contestants see it with the given feedback level:
+// without feedback, the verdict and the score of judged submissions are hidden.
+func (r *Submission) WithFeedback(level FeedbackLevel) *Submission {
+	if level != FeedbackLevelNone || !r.Score.Valid {
+		return r
+	}
+	sub := *r
+	sub.Verdict = VerdictJudged
+	sub.Score = sql.NullFloat64{}
+	sub.Penalty = sql.NullInt64{}
+	return &sub
+}
+
+// DuringContest returns the submission as the contestants see it until the contest ends: scored on the test groups
+// visible during the contest only (see TestGroupVisibility), so that the hidden results do not leak.
+func (r *Submission) DuringContest() *Submission {
+	if !r.Score.Valid || !r.VisibleScore.Valid {
+		return r
+	}
+	sub := *r
+	sub.Score = r.VisibleScore
+	sub.Verdict = r.VisibleVerdict
+	return &sub
+}
+
+// OutputOnly returns whether the submission is an archive of outputs, submitted to an output-only problem.
+func (r *Submission) OutputOnly() bool {
+	return r.Language == LanguageOutputOnly
+}
+
+// AdminLink is the link to the submission in the admin panel.
+func (r *Submission) AdminLink() string {
+	return fmt.Sprintf("/admin/submissions/%d", r.ID)
+}
+
 // GetProblemsSubmissions returns the submissions that belong to a list of problems.
 func GetProblemsSubmissions(db db.DBContext, problemID ...int) ([]*Submission, error) {
 	if len(problemID) == 0 {
@@ -125,3 +123,12 @@ func GetUserProblemSubmissions(db db.DBContext, userID string, problemID ...int)
 	}
 	return result, nil
 }
+
+// GetJudgeErrorSubmissions returns the submissions with the "Judge Error" verdict.
+func GetJudgeErrorSubmissions(db db.DBContext) ([]*Submission, error) {
+	var result []*Submission
+	if err := db.Select(&result, "SELECT * FROM submissions WHERE verdict = ?"+querySubmissionOrderBy, VerdictJudgeError); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return result, nil
+}
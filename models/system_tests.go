@@ -0,0 +1,118 @@
+package models
+
+import (
+	"github.com/jmoiron/sqlx"
+	"github.com/natsukagami/kjudge/db"
+	"github.com/pkg/errors"
+)
+
+// This file handles the pretests and the system tests of contests (Codeforces-style two-phase judging).
+// In contests with system tests, submissions are judged only on the pretest groups until the system tests start.
+// The system tests then judge the best submission of each contestant on each problem on every test, and
+// only these submissions count from then on.
+
+// PretestPhase returns whether the contest's submissions are judged only on the pretests.
+func (c *Contest) PretestPhase() bool {
+	return c.SystemTests && !c.SystemTestsStarted
+}
+
+// ScoredOnPretests returns whether the submission is judged only on the pretests of its contest.
+func (r *Submission) ScoredOnPretests(c *Contest) bool {
+	return c.SystemTests && !(c.SystemTestsStarted && r.SystemTest)
+}
+
+// PretestGroups returns the pretest groups among the test groups, along with the test groups they depend on.
+// If there are none, the problem has no separate pretests, and all test groups are returned.
+func PretestGroups(testGroups []*TestGroupWithTests) []*TestGroupWithTests {
+	included := make(map[int]bool)
+	var include func(tg *TestGroupWithTests)
+	include = func(tg *TestGroupWithTests) {
+		if included[tg.ID] {
+			return
+		}
+		included[tg.ID] = true
+		for _, dep := range tg.Dependencies {
+			include(dep)
+		}
+	}
+	for _, tg := range testGroups {
+		if tg.Pretest {
+			include(tg)
+		}
+	}
+	if len(included) == 0 {
+		return testGroups
+	}
+	var res []*TestGroupWithTests
+	for _, tg := range testGroups {
+		if included[tg.ID] {
+			res = append(res, tg)
+		}
+	}
+	return res
+}
+
+// GetContestsAwaitingSystemTests returns the contests with system tests that have ended,
+// but whose system tests have not started.
+func GetContestsAwaitingSystemTests(db db.DBContext) ([]*Contest, error) {
+	var res []*Contest
+	if err := db.Select(&res, "SELECT * FROM contests WHERE system_tests AND NOT system_tests_started AND datetime(end_time) <= datetime('now')"+queryContestOrderBy); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return res, nil
+}
+
+// StartSystemTests starts the system tests of the contest: the best submission of each contestant on each problem,
+// along with the submissions still being judged, are re-scored on every test.
+// The tests they already ran on are not run again.
+func StartSystemTests(db db.DBContext, c *Contest) error {
+	if !c.SystemTests {
+		return errors.New("the contest has no system tests")
+	}
+	if c.SystemTestsStarted {
+		return errors.New("the system tests have already started")
+	}
+	var subIDs []int
+	if err := db.Select(&subIDs, `SELECT submissions.id FROM submissions JOIN problems ON submissions.problem_id = problems.id
+		WHERE problems.contest_id = ? AND (submissions.verdict = ? OR submissions.id IN (
+			SELECT best_submission_id FROM problem_results WHERE best_submission_id IS NOT NULL))`, c.ID, VerdictIsInQueue); err != nil {
+		return errors.WithStack(err)
+	}
+	c.SystemTestsStarted = true
+	if err := c.Write(db); err != nil {
+		return err
+	}
+	if len(subIDs) == 0 {
+		return nil
+	}
+	query, params, err := sqlx.In("UPDATE submissions SET system_test = 1 WHERE id IN (?)", subIDs)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if _, err := db.Exec(query, params...); err != nil {
+		return errors.WithStack(err)
+	}
+	return RejudgeScore(db, subIDs...)
+}
+
+// SystemTestsProgress is the progress of a contest's system tests.
+type SystemTestsProgress struct {
+	Judged int `db:"judged"`
+	Total  int `db:"total"`
+}
+
+// Done returns whether all submissions of the system tests are judged.
+func (p *SystemTestsProgress) Done() bool {
+	return p.Judged == p.Total
+}
+
+// GetSystemTestsProgress returns the progress of the contest's system tests.
+func GetSystemTestsProgress(db db.DBContext, contestID int) (*SystemTestsProgress, error) {
+	var p SystemTestsProgress
+	if err := db.Get(&p, `SELECT COUNT(*) AS total, COALESCE(SUM(submissions.verdict != ?), 0) AS judged
+		FROM submissions JOIN problems ON submissions.problem_id = problems.id
+		WHERE problems.contest_id = ? AND submissions.system_test`, VerdictIsInQueue, contestID); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return &p, nil
+}
@@ -1,6 +1,10 @@
 package models
 
 import (
+	"database/sql/driver"
+	"strconv"
+	"strings"
+
 	"github.com/jmoiron/sqlx"
 	"github.com/natsukagami/kjudge/db"
 	"github.com/natsukagami/kjudge/models/verify"
@@ -25,13 +29,89 @@ func (t TestScoringMode) verify() error {
 	return verify.String(string(t), verify.Enum(string(TestScoringModeSum), string(TestScoringModeMin), string(TestScoringModeProduct)))
 }
 
+// TestGroupVisibility determines whether the contestants see a test group and its results.
+// The score of the test group counts in all cases. There are:
+// - Visible: The test group is always shown.
+// - HiddenDuringContest: The test group is shown once the contest ends.
+// - HiddenForever: The test group is never shown.
+type TestGroupVisibility string
+
+// All possible values of TestGroupVisibility.
+const (
+	TestGroupVisibilityVisible             TestGroupVisibility = "visible"
+	TestGroupVisibilityHiddenDuringContest TestGroupVisibility = "hidden_during_contest"
+	TestGroupVisibilityHiddenForever       TestGroupVisibility = "hidden_forever"
+)
+
+func (t TestGroupVisibility) verify() error {
+	return verify.String(string(t), verify.Enum(string(TestGroupVisibilityVisible), string(TestGroupVisibilityHiddenDuringContest),
+		string(TestGroupVisibilityHiddenForever)))
+}
+
+// TestGroupDependencies are the test groups of the same problem a test group depends on, stored as a comma-separated
+// list of their IDs. The tests of the dependencies are shared with the test group rather than copied into it:
+// the score of the test group is capped by the results of its dependencies.
+type TestGroupDependencies []int
+
+// Has returns whether the test group with the given ID is a dependency.
+func (d TestGroupDependencies) Has(id int) bool {
+	for _, item := range d {
+		if item == id {
+			return true
+		}
+	}
+	return false
+}
+
+// Value implements driver.Valuer.
+func (d TestGroupDependencies) Value() (driver.Value, error) {
+	items := make([]string, len(d))
+	for i, id := range d {
+		items[i] = strconv.Itoa(id)
+	}
+	return strings.Join(items, ","), nil
+}
+
+// Scan implements sql.Scanner.
+func (d *TestGroupDependencies) Scan(src interface{}) error {
+	var value string
+	switch v := src.(type) {
+	case string:
+		value = v
+	case []byte:
+		value = string(v)
+	case nil:
+	default:
+		return errors.Errorf("cannot scan %T into test group dependencies", src)
+	}
+	*d = nil
+	for _, item := range strings.Split(value, ",") {
+		if item == "" {
+			continue
+		}
+		id, err := strconv.Atoi(item)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		*d = append(*d, id)
+	}
+	return nil
+}
+
 // Verify verifies TestGroup's content.
 func (r *TestGroup) Verify() error {
+	var dependencies error
+	if r.ID != 0 && r.Dependencies.Has(r.ID) {
+		dependencies = errors.New("a test group cannot depend on itself")
+	}
 	return verify.All(map[string]error{
-		"ScoringMode": r.ScoringMode.verify(),
-		"TimeLimit":   verify.NullInt(r.TimeLimit, verify.IntPositive),
-		"MemoryLimit": verify.NullInt(r.MemoryLimit, verify.IntPositive),
-		"Name":        verify.Names(r.Name),
+		"Dependencies": dependencies,
+		"Score":        verify.Float(r.Score, verify.FloatMin(0)),
+		"Visibility":   r.Visibility.verify(),
+		"ScoringMode":  r.ScoringMode.verify(),
+		"TimeLimit":    verify.NullInt(r.TimeLimit, verify.IntPositive),
+		"MemoryLimit":  verify.NullInt(r.MemoryLimit, verify.IntPositive),
+		"Name":         verify.Names(r.Name),
 	})
 }
 
@@ -55,7 +135,97 @@ func (t *TestGroup) DeleteResults(db db.DBContext) error {
 	return nil
 }
 
-// Hidden returns whether the test group is hidden.
+// Hidden returns whether the test group is hidden from the contestants during the contest.
 func (r *TestGroup) Hidden() bool {
-	return r.Score < 0
+	return r.Visibility != TestGroupVisibilityVisible
+}
+
+// VisibleTo returns whether the contestants see the test group, given whether the contest has ended.
+func (r *TestGroup) VisibleTo(contestEnded bool) bool {
+	switch r.Visibility {
+	case TestGroupVisibilityHiddenForever:
+		return false
+	case TestGroupVisibilityHiddenDuringContest:
+		return contestEnded
+	default:
+		return true
+	}
+}
+
+// StopsAtFirstFailure returns whether the remaining tests of the group are skipped after a zero-scored one.
+// Only "min" and "product" groups do, as a single zero-scored test makes their score zero.
+func (r *TestGroup) StopsAtFirstFailure() bool {
+	return r.SkipAfterFailure && (r.ScoringMode == TestScoringModeMin || r.ScoringMode == TestScoringModeProduct)
+}
+
+// VerifyDependencies verifies that the dependencies of the test group are test groups of the same problem,
+// and that they do not depend on the test group in turn.
+func (r *TestGroup) VerifyDependencies(db db.DBContext) error {
+	testGroups, err := GetProblemTestGroups(db, r.ProblemID)
+	if err != nil {
+		return err
+	}
+	byID := make(map[int]*TestGroup)
+	for _, tg := range testGroups {
+		byID[tg.ID] = tg
+	}
+	byID[r.ID] = r
+	for _, id := range r.Dependencies {
+		if _, ok := byID[id]; !ok || id == r.ID {
+			return errors.Errorf("test group %d is not another test group of the problem", id)
+		}
+	}
+	// Look for a path from the dependencies back to the test group.
+	visited := make(map[int]bool)
+	var dependsOnUs func(id int) bool
+	dependsOnUs = func(id int) bool {
+		if id == r.ID {
+			return true
+		}
+		if visited[id] {
+			return false
+		}
+		visited[id] = true
+		tg, ok := byID[id]
+		if !ok {
+			return false
+		}
+		for _, dep := range tg.Dependencies {
+			if dependsOnUs(dep) {
+				return true
+			}
+		}
+		return false
+	}
+	for _, id := range r.Dependencies {
+		if dependsOnUs(id) {
+			return errors.Errorf("test group %s depends on %s in turn", byID[id].Name, r.Name)
+		}
+	}
+	return nil
+}
+
+// RemoveTestGroupDependency removes the test group from the dependencies of the other test groups of its problem.
+// It should be called when the test group is deleted.
+func RemoveTestGroupDependency(db db.DBContext, r *TestGroup) error {
+	testGroups, err := GetProblemTestGroups(db, r.ProblemID)
+	if err != nil {
+		return err
+	}
+	for _, tg := range testGroups {
+		if !tg.Dependencies.Has(r.ID) {
+			continue
+		}
+		var dependencies TestGroupDependencies
+		for _, id := range tg.Dependencies {
+			if id != r.ID {
+				dependencies = append(dependencies, id)
+			}
+		}
+		tg.Dependencies = dependencies
+		if err := tg.Write(db); err != nil {
+			return err
+		}
+	}
+	return nil
 }
@@ -1,6 +1,65 @@
 package models
 
-import "github.com/natsukagami/kjudge/models/verify"
+import (
+	"github.com/natsukagami/kjudge/db"
+	"github.com/natsukagami/kjudge/models/verify"
+	"github.com/pkg/errors"
+)
+
+// VerdictCode is the typed verdict of a test result, while its Verdict holds the message.
+type VerdictCode string
+
+// Defined values for VerdictCode.
+const (
+	VerdictCodeAccepted            VerdictCode = "AC"
+	VerdictCodePartiallyAccepted   VerdictCode = "PA"
+	VerdictCodeWrongAnswer         VerdictCode = "WA"
+	VerdictCodeTimeLimitExceeded   VerdictCode = "TLE"
+	VerdictCodeMemoryLimitExceeded VerdictCode = "MLE"
+	VerdictCodeRuntimeError        VerdictCode = "RE"
+	VerdictCodeOutputLimitExceeded VerdictCode = "OLE"
+	VerdictCodeJudgeError          VerdictCode = "JE"
+	VerdictCodeSkipped             VerdictCode = "SKIP"
+)
+
+var verdictCodeNames = map[VerdictCode]string{
+	VerdictCodeAccepted:            "Accepted",
+	VerdictCodePartiallyAccepted:   "Partially Accepted",
+	VerdictCodeWrongAnswer:         "Wrong Answer",
+	VerdictCodeTimeLimitExceeded:   "Time Limit Exceeded",
+	VerdictCodeMemoryLimitExceeded: "Memory Limit Exceeded",
+	VerdictCodeRuntimeError:        "Runtime Error",
+	VerdictCodeOutputLimitExceeded: "Output Limit Exceeded",
+	VerdictCodeJudgeError:          "Judge Error",
+	VerdictCodeSkipped:             "Skipped",
+}
+
+func (v VerdictCode) verify() error {
+	return verify.String(string(v), verify.Enum(string(VerdictCodeAccepted), string(VerdictCodePartiallyAccepted),
+		string(VerdictCodeWrongAnswer), string(VerdictCodeTimeLimitExceeded), string(VerdictCodeMemoryLimitExceeded),
+		string(VerdictCodeRuntimeError), string(VerdictCodeOutputLimitExceeded), string(VerdictCodeJudgeError),
+		string(VerdictCodeSkipped)))
+}
+
+// Name returns the display name of the verdict, e.g. "Wrong Answer".
+func (v VerdictCode) Name() string {
+	if name, ok := verdictCodeNames[v]; ok {
+		return name
+	}
+	return string(v)
+}
+
+// VerdictCodeOfScore returns the verdict of a test the submission ran successfully on, given its score.
+func VerdictCodeOfScore(score float64) VerdictCode {
+	switch {
+	case score >= 1:
+		return VerdictCodeAccepted
+	case score > 0:
+		return VerdictCodePartiallyAccepted
+	default:
+		return VerdictCodeWrongAnswer
+	}
+}
 
 // Verify verifies that the TestResult is a legit one.
 func (r *TestResult) Verify() error {
@@ -9,5 +68,38 @@ func (r *TestResult) Verify() error {
 		"RunningTime": verify.IntMin(0)(r.RunningTime),
 		"Score":       verify.Float(r.Score, verify.FloatRange(0, 1)),
 		"Verdict":     verify.StringNonEmpty(r.Verdict),
+		"VerdictCode": r.VerdictCode.verify(),
 	})
 }
+
+// Failed returns whether the test result makes a group that stops at its first failure skip its remaining tests,
+// that is it scores zero and is not a judge error, which is not the submission's fault.
+func (r *TestResult) Failed() bool {
+	return r.Score == 0 && r.VerdictCode != VerdictCodeJudgeError
+}
+
+// NewSkippedTestResult returns the result of a test the submission was not run on,
+// as its test group already scores zero.
+func NewSkippedTestResult(subID, testID int) *TestResult {
+	return &TestResult{
+		SubmissionID: subID,
+		TestID:       testID,
+		Verdict:      VerdictCodeSkipped.Name(),
+		VerdictCode:  VerdictCodeSkipped,
+		Score:        0,
+	}
+}
+
+// StripContestTestResultOutputs removes the kept outputs, standard errors and checker messages from the test results
+// of the contest's submissions. Returns the number of test results stripped.
+func StripContestTestResultOutputs(db db.DBContext, contestID int) (int64, error) {
+	res, err := db.Exec(`UPDATE test_results SET output = NULL, stderr = NULL, checker_message = ""
+		WHERE (output IS NOT NULL OR stderr IS NOT NULL OR checker_message != "") AND submission_id IN (
+			SELECT submissions.id FROM submissions JOIN problems ON submissions.problem_id = problems.id
+			WHERE problems.contest_id = ?)`, contestID)
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	n, err := res.RowsAffected()
+	return n, errors.WithStack(err)
+}
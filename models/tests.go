@@ -7,10 +7,28 @@ import (
 	"github.com/pkg/errors"
 )
 
+// TestValidation is the result of running the problem's validator on a test input.
+type TestValidation string
+
+// All possible values of TestValidation.
+const (
+	TestValidationNone    TestValidation = "" // Not validated yet
+	TestValidationValid   TestValidation = "valid"
+	TestValidationInvalid TestValidation = "invalid"
+	TestValidationError   TestValidation = "error" // The validator failed to run on the test
+)
+
+func (t TestValidation) verify() error {
+	return verify.String(string(t), verify.Enum(string(TestValidationNone), string(TestValidationValid), string(TestValidationInvalid),
+		string(TestValidationError)))
+}
+
 // TestGroupWithTests are wrapped test groups with tests included.
 type TestGroupWithTests struct {
 	*TestGroup
 	Tests []*Test
+	// The test groups it depends on, see TestGroupDependencies.
+	Dependencies []*TestGroupWithTests
 }
 
 // GetProblemTests collects test groups and tests from a problem.
@@ -20,7 +38,7 @@ func GetProblemTests(db db.DBContext, problemID int) ([]*TestGroupWithTests, err
 
 // GetProblemTestsMeta is like GetProblemTests, but inputs and outputs are not included.
 func GetProblemTestsMeta(db db.DBContext, problemID int) ([]*TestGroupWithTests, error) {
-	return getProblemTests(db, problemID, "id, name, test_group_id")
+	return getProblemTests(db, problemID, "id, name, test_group_id, validation, validation_message")
 }
 
 // GetProblemTests but allow us to omit cols (input, output)
@@ -55,14 +73,131 @@ func getProblemTests(db db.DBContext, problemID int, cols string) ([]*TestGroupW
 		tg := tgMap[test.TestGroupID]
 		tg.Tests = append(tg.Tests, test)
 	}
-	// Collect the map into a slice.
+	// Collect the map into a slice, linking the dependencies.
 	var res []*TestGroupWithTests
 	for _, tg := range testGroups {
-		res = append(res, tgMap[tg.ID])
+		item := tgMap[tg.ID]
+		for _, id := range tg.Dependencies {
+			if dep, ok := tgMap[id]; ok {
+				item.Dependencies = append(item.Dependencies, dep)
+			}
+		}
+		res = append(res, item)
 	}
 	return res, nil
 }
 
+// VisibleTestGroups returns the test groups the contestants see, given whether the contest has ended.
+// The dependencies they do not see are replaced by their own dependencies, so that the hidden results
+// do not cap the scores the contestants see.
+func VisibleTestGroups(testGroups []*TestGroupWithTests, contestEnded bool) []*TestGroupWithTests {
+	var res []*TestGroupWithTests
+	visible := make(map[int]*TestGroupWithTests)
+	for _, tg := range testGroups {
+		if tg.VisibleTo(contestEnded) {
+			item := &TestGroupWithTests{TestGroup: tg.TestGroup, Tests: tg.Tests}
+			visible[tg.ID] = item
+			res = append(res, item)
+		}
+	}
+	var visibleDependencies func(tg *TestGroupWithTests) []*TestGroupWithTests
+	visibleDependencies = func(tg *TestGroupWithTests) []*TestGroupWithTests {
+		var deps []*TestGroupWithTests
+		for _, dep := range tg.Dependencies {
+			if item, ok := visible[dep.ID]; ok {
+				deps = append(deps, item)
+			} else {
+				deps = append(deps, visibleDependencies(dep)...)
+			}
+		}
+		return deps
+	}
+	for _, tg := range testGroups {
+		if item, ok := visible[tg.ID]; ok {
+			item.Dependencies = visibleDependencies(tg)
+		}
+	}
+	return res
+}
+
+// GetProblemTestOutputPreviews returns the first `length` bytes of each test's expected output in the problem,
+// keyed by the test IDs.
+func GetProblemTestOutputPreviews(db db.DBContext, problemID, length int) (map[int][]byte, error) {
+	var tests []*Test
+	if err := db.Select(&tests, `SELECT tests.id AS id, substr(tests.output, 1, ?) AS output FROM tests
+		JOIN test_groups ON tests.test_group_id = test_groups.id WHERE test_groups.problem_id = ?`, length, problemID); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	previews := make(map[int][]byte)
+	for _, test := range tests {
+		previews[test.ID] = test.Output
+	}
+	return previews, nil
+}
+
+// ValidationSummary counts the tests of some test groups by the results of their validation.
+type ValidationSummary struct {
+	Valid        int
+	NotValidated int
+	// The tests rejected by the validator, along with their test groups.
+	Invalid []InvalidTest
+	// The tests the validator failed to run on, which are neither valid nor invalid.
+	Failed []InvalidTest
+}
+
+// InvalidTest is a test rejected by the validator, or one it failed to run on.
+type InvalidTest struct {
+	TestGroup *TestGroup
+	*Test
+}
+
+// SummarizeValidation sums up the validation of the test groups' tests.
+func SummarizeValidation(testGroups []*TestGroupWithTests) *ValidationSummary {
+	summary := &ValidationSummary{}
+	for _, tg := range testGroups {
+		for _, test := range tg.Tests {
+			switch test.Validation {
+			case TestValidationValid:
+				summary.Valid++
+			case TestValidationInvalid:
+				summary.Invalid = append(summary.Invalid, InvalidTest{TestGroup: tg.TestGroup, Test: test})
+			case TestValidationError:
+				summary.Failed = append(summary.Failed, InvalidTest{TestGroup: tg.TestGroup, Test: test})
+			default:
+				summary.NotValidated++
+			}
+		}
+	}
+	return summary
+}
+
+// ResetProblemTestValidation marks all tests of the problem as not validated, e.g. when its validator changes.
+func ResetProblemTestValidation(db db.DBContext, problemID int) error {
+	_, err := db.Exec(`UPDATE tests SET validation = ?, validation_message = "" WHERE test_group_id IN
+		(SELECT id FROM test_groups WHERE problem_id = ?)`, TestValidationNone, problemID)
+	return errors.WithStack(err)
+}
+
+// ResetTestGroupTestValidation marks all tests of the test group as not validated.
+func ResetTestGroupTestValidation(db db.DBContext, testGroupID int) error {
+	_, err := db.Exec(`UPDATE tests SET validation = ?, validation_message = "" WHERE test_group_id = ?`,
+		TestValidationNone, testGroupID)
+	return errors.WithStack(err)
+}
+
+// FirstFailedTest returns the first test (in the order of the test groups, then the tests) whose result is not
+// accepted, along with the result. Returns nils if there is no such test.
+func FirstFailedTest(testGroups []*TestGroupWithTests, results map[int]*TestResult) (*Test, *TestResult) {
+	for _, tg := range testGroups {
+		for _, test := range tg.Tests {
+			if result, ok := results[test.ID]; ok && result.VerdictCode != VerdictCodeAccepted {
+				return test, result
+			}
+		}
+	}
+	return nil, nil
+}
+
 // Verify verifies Test's contents.
 func (r *Test) Verify() error {
 	if r.Input == nil {
@@ -71,38 +206,55 @@ func (r *Test) Verify() error {
 	if r.Output == nil {
 		return errors.New("output must not be null")
 	}
-	return errors.Wrapf(verify.Names(r.Name), "field name")
+	return verify.All(map[string]error{
+		"Name":       verify.Names(r.Name),
+		"Validation": r.Validation.verify(),
+	})
 }
 
 // ComputeScore returns the score of a test group (with tests), given the test results.
+// The score is capped by the results of the test group's dependencies. Missing test results count as zero.
 func (tg *TestGroupWithTests) ComputeScore(results map[int]*TestResult) float64 {
-	if tg.Score < 0 {
+	return tg.Score * tg.ratio(results)
+}
+
+// Returns the ratio of the test group's score the results get, taking the dependencies into account.
+// A test group without tests gets nothing.
+func (tg *TestGroupWithTests) ratio(results map[int]*TestResult) float64 {
+	if len(tg.Tests) == 0 {
+		return 0
+	}
+	testScore := func(test *Test) float64 {
+		if result, ok := results[test.ID]; ok {
+			return result.Score
+		}
 		return 0
 	}
+	ratio := 1.0
 	switch tg.ScoringMode {
 	case TestScoringModeSum:
 		score := 0.0
 		for _, test := range tg.Tests {
-			result := results[test.ID]
-			score += result.Score
+			score += testScore(test)
 		}
-		return tg.Score * (score / float64(len(tg.Tests)))
+		ratio = score / float64(len(tg.Tests))
 	case TestScoringModeMin:
-		ratio := 1.0
 		for _, test := range tg.Tests {
-			result := results[test.ID]
-			if ratio > result.Score {
-				ratio = result.Score
+			if score := testScore(test); ratio > score {
+				ratio = score
 			}
 		}
-		return tg.Score * ratio
 	case TestScoringModeProduct:
-		ratio := 1.0
 		for _, test := range tg.Tests {
-			result := results[test.ID]
-			ratio *= result.Score
+			ratio *= testScore(test)
+		}
+	default:
+		panic("Unknown Scoring Mode: " + tg.ScoringMode)
+	}
+	for _, dep := range tg.Dependencies {
+		if depRatio := dep.ratio(results); ratio > depRatio {
+			ratio = depRatio
 		}
-		return tg.Score * ratio
 	}
-	panic("Unknown Scoring Mode: " + tg.ScoringMode)
+	return ratio
 }
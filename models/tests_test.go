@@ -0,0 +1,110 @@
+package models
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestComputeScore(t *testing.T) {
+	group := func(mode TestScoringMode, testIDs ...int) *TestGroupWithTests {
+		tg := &TestGroupWithTests{TestGroup: &TestGroup{Score: 100, ScoringMode: mode}}
+		for _, id := range testIDs {
+			tg.Tests = append(tg.Tests, &Test{ID: id})
+		}
+		return tg
+	}
+	results := map[int]*TestResult{
+		1: {VerdictCode: VerdictCodeAccepted, Score: 1},
+		2: {VerdictCode: VerdictCodePartiallyAccepted, Score: 0.5},
+		3: {VerdictCode: VerdictCodeAccepted, Score: 1},
+		4: {VerdictCode: VerdictCodeWrongAnswer, Score: 0},
+		5: NewSkippedTestResult(0, 5),
+		6: {VerdictCode: VerdictCodePartiallyAccepted, Score: 0.8},
+	}
+
+	sum := group(TestScoringModeSum, 1, 2, 3, 6)
+	minimum := group(TestScoringModeMin, 1, 2, 6)
+	product := group(TestScoringModeProduct, 2, 6)
+	failed := group(TestScoringModeMin, 4, 5)
+	missing := group(TestScoringModeSum, 1, 7)
+	empty := group(TestScoringModeSum)
+
+	dependent := group(TestScoringModeSum, 1, 3)
+	dependent.Dependencies = []*TestGroupWithTests{minimum}
+	transitive := group(TestScoringModeSum, 1)
+	transitive.Dependencies = []*TestGroupWithTests{dependent}
+	failedDependency := group(TestScoringModeSum, 1)
+	failedDependency.Dependencies = []*TestGroupWithTests{product, failed}
+	emptyDependency := group(TestScoringModeSum, 1)
+	emptyDependency.Dependencies = []*TestGroupWithTests{empty}
+	betterDependency := group(TestScoringModeMin, 2)
+	betterDependency.Dependencies = []*TestGroupWithTests{sum}
+
+	tests := []struct {
+		name  string
+		tg    *TestGroupWithTests
+		score float64
+	}{
+		{"sum", sum, 82.5},
+		{"min", minimum, 50},
+		{"product", product, 40},
+		{"failed and skipped", failed, 0},
+		{"missing results count as zero", missing, 50},
+		{"no tests", empty, 0},
+		{"capped by a dependency", dependent, 50},
+		{"capped by the dependencies of a dependency", transitive, 50},
+		{"capped by the worst dependency", failedDependency, 0},
+		{"capped by a dependency without tests", emptyDependency, 0},
+		{"not raised by a dependency", betterDependency, 50},
+	}
+	for _, test := range tests {
+		if got := test.tg.ComputeScore(results); math.Abs(got-test.score) > 1e-9 {
+			t.Errorf("%s: got %v, expected %v", test.name, got, test.score)
+		}
+	}
+}
+
+func TestVisibleTestGroups(t *testing.T) {
+	group := func(id int, visibility TestGroupVisibility, deps ...*TestGroupWithTests) *TestGroupWithTests {
+		return &TestGroupWithTests{TestGroup: &TestGroup{ID: id, Name: string(rune('a' + id)), Visibility: visibility}, Dependencies: deps}
+	}
+	visible := group(1, TestGroupVisibilityVisible)
+	hidden := group(2, TestGroupVisibilityHiddenDuringContest, visible)
+	forever := group(3, TestGroupVisibilityHiddenForever)
+	// Depends on the visible group through the hidden one.
+	dependent := group(4, TestGroupVisibilityVisible, hidden, forever)
+	testGroups := []*TestGroupWithTests{visible, hidden, forever, dependent}
+
+	names := func(testGroups []*TestGroupWithTests) []string {
+		res := []string{}
+		for _, tg := range testGroups {
+			res = append(res, tg.Name)
+		}
+		return res
+	}
+	find := func(testGroups []*TestGroupWithTests, id int) *TestGroupWithTests {
+		for _, tg := range testGroups {
+			if tg.ID == id {
+				return tg
+			}
+		}
+		t.Fatalf("test group %d not found", id)
+		return nil
+	}
+
+	during := VisibleTestGroups(testGroups, false)
+	if got, want := names(during), []string{"b", "e"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("during the contest: expected groups %v, got %v", want, got)
+	}
+	if got, want := names(find(during, 4).Dependencies), []string{"b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("during the contest: expected dependencies %v, got %v", want, got)
+	}
+	after := VisibleTestGroups(testGroups, true)
+	if got, want := names(find(after, 4).Dependencies), []string{"c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("after the contest: expected dependencies %v, got %v", want, got)
+	}
+	if len(dependent.Dependencies) != 2 {
+		t.Errorf("expected the test groups to be left untouched, got %d dependencies", len(dependent.Dependencies))
+	}
+}
@@ -0,0 +1,12 @@
+#!/usr/bin/env sh
+
+set -e
+
+# Downloads testlib.h into the embedded assets, to be given to the compilers of testlib checkers.
+# Set TESTLIB_VERSION to fetch another release.
+
+TESTLIB_VERSION="${TESTLIB_VERSION:-0.9.41}"
+TARGET="embed/assets/testlib.h"
+
+curl -sSfL "https://raw.githubusercontent.com/MikeMirzayanov/testlib/${TESTLIB_VERSION}/testlib.h" -o "${TARGET}"
+echo "Fetched testlib ${TESTLIB_VERSION} into ${TARGET}"
@@ -4,5 +4,7 @@ set -ev
 
 # Frontend templates
 cd frontend && yarn && yarn run --prod build && cd ..
+# testlib.h, for testlib checkers
+scripts/fetch_testlib.sh
 # Go source code
 go generate
@@ -5,6 +5,7 @@ import (
 	"github.com/labstack/echo/v4"
 	"github.com/natsukagami/kjudge/db"
 	"github.com/natsukagami/kjudge/server/auth"
+	"github.com/natsukagami/kjudge/worker/remote"
 )
 
 // Group represents a router Group with handling functions.
@@ -12,14 +13,18 @@ type Group struct {
 	*echo.Group
 	db *db.DB
 	au *auth.AdminAuth
+
+	workers *remote.Registry
 }
 
 // New creates a new group.
-func New(db *db.DB, au *auth.AdminAuth, unauthed *echo.Group) (*Group, error) {
+func New(db *db.DB, au *auth.AdminAuth, workers *remote.Registry, unauthed *echo.Group) (*Group, error) {
 	grp := &Group{
 		Group: unauthed,
 		db:    db,
 		au:    au,
+
+		workers: workers,
 	}
 	// Authentication
 	unauthed.GET("/login", grp.LoginGet)
@@ -42,6 +47,9 @@ func New(db *db.DB, au *auth.AdminAuth, unauthed *echo.Group) (*Group, error) {
 	g.POST("/contests/:id/delete", grp.ContestDelete)
 	g.POST("/contests/:id/add_problem", grp.ContestAddProblem)
 	g.POST("/contests/:id/rejudge", grp.ContestRejudgePost)
+	g.POST("/contests/:id/system_tests", grp.ContestSystemTestsPost)
+	g.GET("/contests/:id/preflight", grp.ContestPreflightGet)
+	g.POST("/contests/:id/release_hold", grp.ContestReleaseHoldPost)
 	// Contest Announcements
 	g.GET("/contests/:id/announcements", grp.AnnouncementsGet)
 	g.POST("/contests/:id/announcements", grp.AnnouncementAddPost)
@@ -51,8 +59,13 @@ func New(db *db.DB, au *auth.AdminAuth, unauthed *echo.Group) (*Group, error) {
 	g.POST("/problems/:id", grp.ProblemEdit)
 	g.POST("/problems/:id/add_test_group", grp.ProblemAddTestGroup)
 	g.POST("/problems/:id/add_file", grp.ProblemAddFile)
+	g.POST("/problems/:id/add_language_limit", grp.ProblemAddLanguageLimit)
 	g.POST("/problems/:id/delete", grp.ProblemDelete)
 	g.POST("/problems/:id/rejudge", grp.ProblemRejudgePost)
+	g.POST("/problems/:id/generate_outputs", grp.ProblemGenerateOutputsPost)
+	g.POST("/problems/:id/validate", grp.ProblemValidatePost)
+	g.POST("/problems/:id/add_model_solution", grp.ProblemAddModelSolution)
+	g.POST("/problems/:id/invoke", grp.ProblemInvokePost)
 	// Test groups
 	g.GET("/test_groups/:id", grp.TestGroupGet)
 	g.POST("/test_groups/:id/upload_single", grp.TestGroupUploadSingle)
@@ -60,6 +73,9 @@ func New(db *db.DB, au *auth.AdminAuth, unauthed *echo.Group) (*Group, error) {
 	g.POST("/test_groups/:id", grp.TestGroupEdit)
 	g.POST("/test_groups/:id/delete", grp.TestGroupDelete)
 	g.POST("/test_groups/:id/rejudge", grp.TestGroupRejudgePost)
+	g.POST("/test_groups/:id/dependencies", grp.TestGroupDependenciesPost)
+	g.POST("/test_groups/:id/generate_outputs", grp.TestGroupGenerateOutputsPost)
+	g.POST("/test_groups/:id/validate", grp.TestGroupValidatePost)
 	// Test
 	g.GET("/tests/:id/input", grp.TestInput)
 	g.GET("/tests/:id/output", grp.TestOutput)
@@ -68,6 +84,14 @@ func New(db *db.DB, au *auth.AdminAuth, unauthed *echo.Group) (*Group, error) {
 	g.GET("/files/:id", grp.FileGet)
 	g.POST("/files/:id/delete", grp.FileDelete)
 	g.POST("/files/:id/compile", grp.FileCompile)
+	g.POST("/files/:id/reference", grp.FileReferencePost)
+	// Admin tasks
+	g.GET("/tasks/:id", grp.AdminTaskGet)
+	// Model solutions
+	g.GET("/model_solutions/:id", grp.ModelSolutionGet)
+	g.POST("/model_solutions/:id/delete", grp.ModelSolutionDelete)
+	// Language limits
+	g.POST("/language_limits/:id/delete", grp.LanguageLimitDelete)
 	// Users
 	g.GET("/users", grp.UsersGet)
 	g.POST("/users", grp.UsersAdd)
@@ -81,8 +105,11 @@ func New(db *db.DB, au *auth.AdminAuth, unauthed *echo.Group) (*Group, error) {
 	g.GET("/submissions/:id/verdict", grp.SubmissionVerdictGet)
 	g.GET("/submissions/:id/binary", grp.SubmissionBinaryGet)
 	g.POST("/rejudge", grp.RejudgePost)
+	g.GET("/judge_errors", grp.JudgeErrorsGet)
 	// Jobs
 	g.GET("/jobs", grp.JobsGet)
+	g.POST("/jobs/retry_dead", grp.JobsRetryDeadPost)
+	g.POST("/jobs/:id/retry", grp.JobRetryPost)
 	// Batch users
 	g.GET("/batch_users/empty", grp.BatchUsersEmptyGet)
 	g.GET("/batch_users/generate", grp.BatchUsersGenerateGet)
@@ -0,0 +1,91 @@
+package admin
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"github.com/natsukagami/kjudge/db"
+	"github.com/natsukagami/kjudge/models"
+	"github.com/natsukagami/kjudge/server/httperr"
+	"github.com/natsukagami/kjudge/worker"
+	"github.com/pkg/errors"
+)
+
+// AdminTaskCtx is the context of an admin task, common to the reports of all types of tasks.
+type AdminTaskCtx struct {
+	Task    *models.AdminTask
+	Contest *models.Contest
+	Problem *models.Problem
+	// The test group the task is limited to, nil if it runs on the whole problem.
+	TestGroup *models.TestGroup
+}
+
+func getAdminTask(db db.DBContext, c echo.Context) (*AdminTaskCtx, error) {
+	idStr := c.Param("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		return nil, httperr.NotFoundf("Task not found: %v", idStr)
+	}
+	task, err := models.GetAdminTask(db, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, httperr.NotFoundf("Task not found: %v", idStr)
+	} else if err != nil {
+		return nil, err
+	}
+	problem, err := models.GetProblem(db, task.ProblemID)
+	if err != nil {
+		return nil, err
+	}
+	contest, err := models.GetContest(db, problem.ContestID)
+	if err != nil {
+		return nil, err
+	}
+	ctx := &AdminTaskCtx{Task: task, Contest: contest, Problem: problem}
+	if task.TestGroupID.Valid {
+		if ctx.TestGroup, err = models.GetTestGroup(db, int(task.TestGroupID.Int64)); err != nil {
+			return nil, err
+		}
+	}
+	return ctx, nil
+}
+
+// AdminTaskGet implements GET /admin/tasks/:id
+// It renders the report of the task once it is done, and its status until then.
+func (g *Group) AdminTaskGet(c echo.Context) error {
+	ctx, err := getAdminTask(g.db, c)
+	if err != nil {
+		return err
+	}
+	switch ctx.Task.Type {
+	case models.AdminTaskTypeGenerate:
+		gctx := &GenerateCtx{AdminTaskCtx: ctx}
+		if ctx.Task.Done() && ctx.Task.Error == "" {
+			gctx.GenerateReport = &worker.GenerateReport{}
+			if err := ctx.Task.ReadReport(gctx.GenerateReport); err != nil {
+				return err
+			}
+		}
+		return c.Render(http.StatusOK, "admin/generate_report", gctx)
+	case models.AdminTaskTypeValidate:
+		vctx := &ValidateCtx{AdminTaskCtx: ctx}
+		if ctx.Task.Done() && ctx.Task.Error == "" {
+			vctx.ValidateReport = &worker.ValidateReport{}
+			if err := ctx.Task.ReadReport(vctx.ValidateReport); err != nil {
+				return err
+			}
+		}
+		return c.Render(http.StatusOK, "admin/validate_report", vctx)
+	case models.AdminTaskTypeInvoke:
+		ictx := &InvocationCtx{AdminTaskCtx: ctx}
+		if ctx.Task.Done() && ctx.Task.Error == "" {
+			ictx.InvocationReport = &worker.InvocationReport{}
+			if err := ctx.Task.ReadReport(ictx.InvocationReport); err != nil {
+				return err
+			}
+		}
+		return c.Render(http.StatusOK, "admin/invocation", ictx)
+	}
+	return httperr.NotFoundf("Unknown task type: %s", ctx.Task.Type)
+}
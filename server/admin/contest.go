@@ -10,19 +10,29 @@ import (
 	"github.com/natsukagami/kjudge/db"
 	"github.com/natsukagami/kjudge/models"
 	"github.com/natsukagami/kjudge/server/httperr"
+	"github.com/natsukagami/kjudge/worker"
 	"github.com/pkg/errors"
 )
 
 // ProblemForm is a form for creating/updating a problem.
 type ProblemForm struct {
-	DisplayName               string               `form:"display_name"`
-	MemoryLimit               int                  `form:"memory_limit"`
-	Name                      string               `form:"name"`
-	PenaltyPolicy             models.PenaltyPolicy `form:"penalty_policy"`
-	ScoringMode               models.ScoringMode   `form:"scoring_mode"`
-	TimeLimit                 int                  `form:"time_limit"`
-	MaxSubmissionsCount       int                  `form:"max_submissions_count"`
-	SecondsBetweenSubmissions int                  `form:"seconds_between_submissions"`
+	DisplayName               string                `form:"display_name"`
+	MemoryLimit               int                   `form:"memory_limit"`
+	Name                      string                `form:"name"`
+	PenaltyPolicy             models.PenaltyPolicy  `form:"penalty_policy"`
+	ScoringMode               models.ScoringMode    `form:"scoring_mode"`
+	TimeLimit                 int                   `form:"time_limit"`
+	MaxSubmissionsCount       int                   `form:"max_submissions_count"`
+	SecondsBetweenSubmissions int                   `form:"seconds_between_submissions"`
+	Languages                 models.LanguageSet    `form:"languages"`
+	ComparisonMode            models.ComparisonMode `form:"comparison_mode"`
+	ComparisonAbsEpsilon      float64               `form:"comparison_abs_epsilon"`
+	ComparisonRelEpsilon      float64               `form:"comparison_rel_epsilon"`
+	FeedbackLevel             models.FeedbackLevel  `form:"feedback_level"`
+	OutputOnly                bool                  `form:"output_only"`
+	OutputPattern             string                `form:"output_pattern"`
+	InputFile                 string                `form:"input_file"`
+	OutputFile                string                `form:"output_file"`
 }
 
 // Bind binds the form's content into the Problem.
@@ -35,6 +45,15 @@ func (f *ProblemForm) Bind(p *models.Problem) {
 	p.TimeLimit = f.TimeLimit
 	p.MaxSubmissionsCount = f.MaxSubmissionsCount
 	p.SecondsBetweenSubmissions = f.SecondsBetweenSubmissions
+	p.Languages = f.Languages
+	p.ComparisonMode = f.ComparisonMode
+	p.ComparisonAbsEpsilon = f.ComparisonAbsEpsilon
+	p.ComparisonRelEpsilon = f.ComparisonRelEpsilon
+	p.FeedbackLevel = f.FeedbackLevel
+	p.OutputOnly = f.OutputOnly
+	p.OutputPattern = f.OutputPattern
+	p.InputFile = f.InputFile
+	p.OutputFile = f.OutputFile
 }
 
 // ProblemForm produces an edit form from the problem.
@@ -48,6 +67,15 @@ func ProblemToForm(p *models.Problem) ProblemForm {
 	f.TimeLimit = p.TimeLimit
 	f.MaxSubmissionsCount = p.MaxSubmissionsCount
 	f.SecondsBetweenSubmissions = p.SecondsBetweenSubmissions
+	f.Languages = p.Languages
+	f.ComparisonMode = p.ComparisonMode
+	f.ComparisonAbsEpsilon = p.ComparisonAbsEpsilon
+	f.ComparisonRelEpsilon = p.ComparisonRelEpsilon
+	f.FeedbackLevel = p.FeedbackLevel
+	f.OutputOnly = p.OutputOnly
+	f.OutputPattern = p.OutputPattern
+	f.InputFile = p.InputFile
+	f.OutputFile = p.OutputFile
 	return f
 }
 
@@ -60,6 +88,11 @@ type ContestCtx struct {
 	Problems         []*models.Problem
 	ProblemForm      ProblemForm
 	ProblemFormError error
+
+	// The progress of the system tests, if they have started.
+	SystemTestsProgress *models.SystemTestsProgress
+	// The result of the pre-flight check, only shown on the contest's page.
+	Preflight *worker.PreflightReport
 }
 
 func getContest(db db.DBContext, c echo.Context) (*ContestCtx, error) {
@@ -78,15 +111,29 @@ func getContest(db db.DBContext, c echo.Context) (*ContestCtx, error) {
 	if err != nil {
 		return nil, err
 	}
+	var progress *models.SystemTestsProgress
+	if contest.SystemTestsStarted {
+		if progress, err = models.GetSystemTestsProgress(db, contest.ID); err != nil {
+			return nil, err
+		}
+	}
 	return &ContestCtx{
-		Contest:  contest,
-		Problems: problems,
-		Form:     *ContestToForm(contest),
+		Contest:             contest,
+		Problems:            problems,
+		Form:                *ContestToForm(contest),
+		SystemTestsProgress: progress,
 		ProblemForm: ProblemForm{
 			TimeLimit:     1000,
 			MemoryLimit:   262144,
 			ScoringMode:   models.ScoringModeBest,
 			PenaltyPolicy: models.PenaltyPolicyNone,
+
+			ComparisonMode:       models.ComparisonModeDiff,
+			ComparisonAbsEpsilon: 1e-6,
+			ComparisonRelEpsilon: 1e-6,
+
+			FeedbackLevel: models.FeedbackLevelFull,
+			OutputPattern: "output?.txt",
 		},
 	}, nil
 }
@@ -105,6 +152,9 @@ func (g *Group) ContestGet(c echo.Context) error {
 	if err != nil {
 		return err
 	}
+	if ctx.Preflight, err = worker.Preflight(g.db, ctx.Contest); err != nil {
+		return err
+	}
 	return ctx.Render(c)
 }
 
@@ -132,6 +182,11 @@ func (g *Group) ContestEdit(c echo.Context) error {
 		return httperr.BindFail(err)
 	}
 	form.Bind(&nw)
+	// A contest starting at another time, or held differently, gets checked again when it starts.
+	if !nw.StartTime.Equal(ctx.Contest.StartTime) || nw.PreflightHold != ctx.Contest.PreflightHold {
+		nw.Held = false
+		nw.HoldChecked = false
+	}
 	if err := nw.Write(g.db); err != nil {
 		ctx.Form = form
 		ctx.FormError = err
@@ -207,3 +262,23 @@ func (g *Group) ContestRejudgePost(c echo.Context) error {
 	}
 	return c.Redirect(http.StatusSeeOther, fmt.Sprintf("/admin/contests/%d/submissions", ctx.ID))
 }
+
+// ContestSystemTestsPost implements POST /admin/contests/:id/system_tests
+func (g *Group) ContestSystemTestsPost(c echo.Context) error {
+	ctx, err := getContest(g.db, c)
+	if err != nil {
+		return err
+	}
+	tx, err := g.db.Beginx()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer db.Rollback(tx)
+	if err := models.StartSystemTests(tx, ctx.Contest); err != nil {
+		return httperr.BadRequestf("Cannot start the system tests: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return errors.WithStack(err)
+	}
+	return c.Redirect(http.StatusSeeOther, fmt.Sprintf("/admin/contests/%d", ctx.ID))
+}
@@ -43,6 +43,9 @@ type ContestForm struct {
 	EndTime              Timestamp                   `form:"end_time"`
 	ContestType          models.ContestType          `form:"contest_type"`
 	ScoreboardViewStatus models.ScoreboardViewStatus `form:"scoreboard_view_status"`
+	Languages            models.LanguageSet          `form:"languages"`
+	SystemTests          bool                        `form:"system_tests"`
+	PreflightHold        bool                        `form:"preflight_hold"`
 }
 
 // ContestToForm creates a form with the initial values of the contest.
@@ -53,6 +56,9 @@ func ContestToForm(c *models.Contest) *ContestForm {
 		EndTime:              Timestamp(c.EndTime),
 		ContestType:          c.ContestType,
 		ScoreboardViewStatus: c.ScoreboardViewStatus,
+		Languages:            c.Languages,
+		SystemTests:          c.SystemTests,
+		PreflightHold:        c.PreflightHold,
 	}
 }
 
@@ -63,6 +69,9 @@ func (f *ContestForm) Bind(c *models.Contest) {
 	c.EndTime = time.Time(f.EndTime)
 	c.ContestType = f.ContestType
 	c.ScoreboardViewStatus = f.ScoreboardViewStatus
+	c.Languages = f.Languages
+	c.SystemTests = f.SystemTests
+	c.PreflightHold = f.PreflightHold
 }
 
 // ContestsGet handles GET /admin/contests
@@ -47,9 +47,32 @@ func (g *Group) FileDelete(c echo.Context) error {
 	if err != nil {
 		return err
 	}
-	if err := file.Delete(g.db); err != nil {
+	tx, err := g.db.Beginx()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer db.Rollback(tx)
+	problem, err := models.GetProblem(tx, file.ProblemID)
+	if err != nil {
+		return err
+	}
+	if problem.ReferenceSolution == file.Filename {
+		problem.ReferenceSolution = ""
+		if err := problem.Write(tx); err != nil {
+			return err
+		}
+	}
+	if err := file.Delete(tx); err != nil {
 		return err
 	}
+	if file.Filename == worker.ValidatorFilename {
+		if err := models.ResetProblemTestValidation(tx, file.ProblemID); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return errors.WithStack(err)
+	}
 	return c.Redirect(http.StatusSeeOther, fmt.Sprintf("/admin/problems/%d#files", file.ProblemID))
 }
 
@@ -93,8 +116,38 @@ func (g *Group) FileCompile(c echo.Context) error {
 	if err := output.Write(tx); err != nil {
 		return err
 	}
+	if output.Filename == worker.ValidatorFilename {
+		if err := models.ResetProblemTestValidation(tx, file.ProblemID); err != nil {
+			return err
+		}
+	}
 	if err := tx.Commit(); err != nil {
 		return errors.WithStack(err)
 	}
 	return c.Redirect(http.StatusSeeOther, fmt.Sprintf("/admin/problems/%d#files", file.ProblemID))
 }
+
+// FileReferencePost implements POST /admin/files/:id/reference
+// It makes the file the problem's reference solution, or unmarks it if it already is.
+func (g *Group) FileReferencePost(c echo.Context) error {
+	file, err := getFile(g.db, c)
+	if err != nil {
+		return err
+	}
+	if !file.Compilable() {
+		return httperr.BadRequestf("File is not a compilable file.")
+	}
+	problem, err := models.GetProblem(g.db, file.ProblemID)
+	if err != nil {
+		return err
+	}
+	if problem.ReferenceSolution == file.Filename {
+		problem.ReferenceSolution = ""
+	} else {
+		problem.ReferenceSolution = file.Filename
+	}
+	if err := problem.Write(g.db); err != nil {
+		return err
+	}
+	return c.Redirect(http.StatusSeeOther, fmt.Sprintf("/admin/problems/%d#files", file.ProblemID))
+}
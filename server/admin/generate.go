@@ -0,0 +1,32 @@
+package admin
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/natsukagami/kjudge/models"
+	"github.com/natsukagami/kjudge/server/httperr"
+	"github.com/natsukagami/kjudge/worker"
+)
+
+// GenerateCtx is the context for rendering admin/generate_report.
+type GenerateCtx struct {
+	*AdminTaskCtx
+	// The report, nil until the task is done.
+	*worker.GenerateReport
+}
+
+// Queues generating the expected outputs of the problem's tests (limited to the test group if it is valid)
+// with its reference solution, and redirects to the task.
+func (g *Group) generateOutputs(c echo.Context, problem *models.Problem, testGroupID sql.NullInt64) error {
+	if problem.ReferenceSolution == "" {
+		return httperr.BadRequestf("The problem has no reference solution.")
+	}
+	task := models.NewAdminTask(problem.ID, models.AdminTaskTypeGenerate, testGroupID)
+	if err := models.StartAdminTask(g.db, task); err != nil {
+		return err
+	}
+	return c.Redirect(http.StatusSeeOther, fmt.Sprintf("/admin/tasks/%d", task.ID))
+}
@@ -7,17 +7,19 @@ import (
 	"github.com/labstack/echo/v4"
 	"github.com/natsukagami/kjudge/db"
 	"github.com/natsukagami/kjudge/models"
+	"github.com/natsukagami/kjudge/worker/remote"
 )
 
 // HomeCtx is a context for rendering Home page.
 type HomeCtx struct {
 	Contests []*models.Contest
 	Queue    *models.QueueOverview
+	Workers  []*remote.Status
 
 	NewVersionMessage string
 }
 
-func getHomeCtx(db db.DBContext, c echo.Context) (*HomeCtx, error) {
+func getHomeCtx(db db.DBContext, workers *remote.Registry, c echo.Context) (*HomeCtx, error) {
 	contests, err := models.GetContestsUnfinished(db)
 	if err != nil {
 		return nil, err
@@ -31,7 +33,7 @@ func getHomeCtx(db db.DBContext, c echo.Context) (*HomeCtx, error) {
 		log.Printf("Falied to get kjudge's release version: %+v", err)
 		message = ""
 	}
-	return &HomeCtx{Contests: contests, Queue: queue, NewVersionMessage: message}, nil
+	return &HomeCtx{Contests: contests, Queue: queue, Workers: workers.Workers(), NewVersionMessage: message}, nil
 }
 
 // Render renders the context.
@@ -41,7 +43,7 @@ func (h *HomeCtx) Render(c echo.Context) error {
 
 // Home renders the home page.
 func (g *Group) Home(c echo.Context) error {
-	ctx, err := getHomeCtx(g.db, c)
+	ctx, err := getHomeCtx(g.db, g.workers, c)
 	if err != nil {
 		return err
 	}
@@ -1,11 +1,15 @@
 package admin
 
 import (
+	"database/sql"
 	"net/http"
+	"strconv"
 
 	"github.com/labstack/echo/v4"
 	"github.com/natsukagami/kjudge/db"
 	"github.com/natsukagami/kjudge/models"
+	"github.com/natsukagami/kjudge/server/httperr"
+	"github.com/pkg/errors"
 )
 
 // JobsCtx is a context for rendering jobs.
@@ -72,3 +76,36 @@ func (g *Group) JobsGet(c echo.Context) error {
 	}
 	return ctx.Render(c)
 }
+
+// JobRetryPost implements POST "/admin/jobs/:id/retry".
+func (g *Group) JobRetryPost(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return httperr.NotFoundf("Job not found: %s", c.Param("id"))
+	}
+	if _, err := models.GetJob(g.db, id); errors.Is(err, sql.ErrNoRows) {
+		return httperr.NotFoundf("Job not found: %s", c.Param("id"))
+	} else if err != nil {
+		return err
+	}
+	if err := models.RetryJobs(g.db, id); err != nil {
+		return err
+	}
+	return c.Redirect(http.StatusSeeOther, "/admin/jobs")
+}
+
+// JobsRetryDeadPost implements POST "/admin/jobs/retry_dead".
+func (g *Group) JobsRetryDeadPost(c echo.Context) error {
+	jobs, err := models.GetDeadJobs(g.db)
+	if err != nil {
+		return err
+	}
+	var id []int
+	for _, job := range jobs {
+		id = append(id, job.ID)
+	}
+	if err := models.RetryJobs(g.db, id...); err != nil {
+		return err
+	}
+	return c.Redirect(http.StatusSeeOther, "/admin/jobs")
+}
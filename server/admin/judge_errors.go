@@ -0,0 +1,28 @@
+package admin
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/natsukagami/kjudge/models"
+)
+
+// JudgeError is a submission the judge failed on, as listed in the admin alert banner.
+type JudgeError struct {
+	ID    int    `json:"id"`
+	Link  string `json:"link"`
+	Error string `json:"error"`
+}
+
+// JudgeErrorsGet implements GET /admin/judge_errors, listing the submissions with the "Judge Error" verdict.
+func (g *Group) JudgeErrorsGet(c echo.Context) error {
+	subs, err := models.GetJudgeErrorSubmissions(g.db)
+	if err != nil {
+		return err
+	}
+	list := make([]JudgeError, 0, len(subs))
+	for _, sub := range subs {
+		list = append(list, JudgeError{ID: sub.ID, Link: sub.AdminLink(), Error: sub.JudgeError})
+	}
+	return c.JSON(http.StatusOK, list)
+}
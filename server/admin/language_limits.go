@@ -0,0 +1,100 @@
+package admin
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"github.com/natsukagami/kjudge/db"
+	"github.com/natsukagami/kjudge/models"
+	"github.com/natsukagami/kjudge/server/httperr"
+	"github.com/pkg/errors"
+)
+
+// LanguageLimitForm is a form for overriding the limits adjustment of a language on a problem.
+type LanguageLimitForm struct {
+	Language         models.Language `form:"language"`
+	TimeMultiplier   float64         `form:"time_multiplier"`
+	TimeOffset       int             `form:"time_offset"`
+	MemoryMultiplier float64         `form:"memory_multiplier"`
+	MemoryOffset     int             `form:"memory_offset"`
+}
+
+// Bind binds the form's content into the override.
+func (f *LanguageLimitForm) Bind(l *models.ProblemLanguageLimit) {
+	l.Language = f.Language
+	l.TimeMultiplier = f.TimeMultiplier
+	l.TimeOffset = f.TimeOffset
+	l.MemoryMultiplier = f.MemoryMultiplier
+	l.MemoryOffset = f.MemoryOffset
+}
+
+// LanguageLimitRow is a language along with its limits adjustment on the problem.
+type LanguageLimitRow struct {
+	Language *models.LanguageSpec
+	// The problem's override, nil if the language's default is used.
+	Override *models.ProblemLanguageLimit
+}
+
+// Collects the limits adjustment of every language on the problem.
+func languageLimitRows(overrides []*models.ProblemLanguageLimit) []*LanguageLimitRow {
+	var rows []*LanguageLimitRow
+	for _, l := range models.Languages() {
+		row := &LanguageLimitRow{Language: l}
+		for _, o := range overrides {
+			if o.Language == l.ID {
+				row.Override = o
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// ProblemAddLanguageLimit implements POST /admin/problems/:id/add_language_limit
+func (g *Group) ProblemAddLanguageLimit(c echo.Context) error {
+	ctx, err := g.getProblem(c)
+	if err != nil {
+		return err
+	}
+	if err := c.Bind(&ctx.LanguageLimitForm); err != nil {
+		return httperr.BindFail(err)
+	}
+	var limit models.ProblemLanguageLimit
+	ctx.LanguageLimitForm.Bind(&limit)
+	tx, err := g.db.Beginx()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer db.Rollback(tx)
+	if err := ctx.Problem.WriteLanguageLimit(tx, &limit); err != nil {
+		ctx.EditForm = ProblemToForm(ctx.Problem)
+		ctx.LanguageLimitFormError = err
+		return g.problemRender(ctx, c)
+	}
+	if err := tx.Commit(); err != nil {
+		return errors.WithStack(err)
+	}
+	return c.Redirect(http.StatusSeeOther, fmt.Sprintf("/admin/problems/%d#language-limits", ctx.Problem.ID))
+}
+
+// LanguageLimitDelete implements POST /admin/language_limits/:id/delete
+func (g *Group) LanguageLimitDelete(c echo.Context) error {
+	idStr := c.Param("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		return httperr.NotFoundf("Language limit not found: %v", idStr)
+	}
+	limit, err := models.GetProblemLanguageLimit(g.db, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return httperr.NotFoundf("Language limit not found: %v", idStr)
+	} else if err != nil {
+		return err
+	}
+	if err := limit.Delete(g.db); err != nil {
+		return err
+	}
+	return c.Redirect(http.StatusSeeOther, fmt.Sprintf("/admin/problems/%d#language-limits", limit.ProblemID))
+}
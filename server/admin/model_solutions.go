@@ -0,0 +1,170 @@
+package admin
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/natsukagami/kjudge/db"
+	"github.com/natsukagami/kjudge/models"
+	"github.com/natsukagami/kjudge/server/httperr"
+	"github.com/natsukagami/kjudge/worker"
+	"github.com/pkg/errors"
+)
+
+// ModelSolutionForm is a form for adding model solutions to a problem, along with their expected outcome.
+// The solutions themselves are the uploaded files.
+type ModelSolutionForm struct {
+	Expected       models.ExpectedOutcome `form:"expected"`
+	ExpectedScores string                 `form:"expected_scores"`
+}
+
+// Bind binds the form's content into the model solution.
+func (f *ModelSolutionForm) Bind(m *models.ModelSolution) error {
+	scores, err := models.ParseExpectedScores(f.ExpectedScores)
+	if err != nil {
+		return errors.Wrap(err, "expected scores")
+	}
+	m.Expected = f.Expected
+	m.ExpectedScores = nil
+	if f.Expected == models.ExpectedOutcomeScores {
+		m.ExpectedScores = scores
+	}
+	return nil
+}
+
+// InvocationCtx is the context for rendering admin/invocation.
+type InvocationCtx struct {
+	*AdminTaskCtx
+	// The report, nil until the task is done.
+	*worker.InvocationReport
+}
+
+func getModelSolution(db db.DBContext, c echo.Context) (*models.ModelSolution, error) {
+	idStr := c.Param("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		return nil, httperr.NotFoundf("Model solution not found: %v", idStr)
+	}
+	solution, err := models.GetModelSolution(db, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, httperr.NotFoundf("Model solution not found: %v", idStr)
+	} else if err != nil {
+		return nil, err
+	}
+	return solution, nil
+}
+
+// ProblemAddModelSolution implements POST /admin/problems/:id/add_model_solution
+// Uploading a solution with the name of an existing one replaces it.
+func (g *Group) ProblemAddModelSolution(c echo.Context) error {
+	ctx, err := g.getProblem(c)
+	if err != nil {
+		return err
+	}
+	if err := c.Bind(&ctx.ModelSolutionForm); err != nil {
+		return httperr.BindFail(err)
+	}
+	form, err := c.MultipartForm()
+	if err != nil {
+		return httperr.BindFail(err)
+	}
+	existing := make(map[string]*models.ModelSolution)
+	for _, m := range ctx.ModelSolutions {
+		existing[m.Name] = m
+	}
+	tx, err := g.db.Beginx()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer db.Rollback(tx)
+	for _, file := range form.File["file"] {
+		solution, err := g.readModelSolution(ctx, file)
+		if err == nil {
+			if m, ok := existing[solution.Name]; ok {
+				solution.ID = m.ID
+			}
+			err = solution.Write(tx)
+		}
+		if err != nil {
+			ctx.EditForm = ProblemToForm(ctx.Problem)
+			ctx.ModelSolutionFormError = errors.Wrapf(err, "model solution %s", file.Filename)
+			return g.problemRender(ctx, c)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return errors.WithStack(err)
+	}
+	return c.Redirect(http.StatusSeeOther, fmt.Sprintf("/admin/problems/%d#model-solutions", ctx.Problem.ID))
+}
+
+// Reads an uploaded model solution, with the expected outcome of the form.
+func (g *Group) readModelSolution(ctx *ProblemCtx, file *multipart.FileHeader) (*models.ModelSolution, error) {
+	language, err := models.LanguageByExt(filepath.Ext(file.Filename))
+	if err != nil {
+		return nil, err
+	}
+	r, err := file.Open()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer r.Close()
+	source, err := io.ReadAll(r)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	solution := &models.ModelSolution{ProblemID: ctx.Problem.ID, Name: file.Filename, Language: language, Source: source}
+	if err := ctx.ModelSolutionForm.Bind(solution); err != nil {
+		return nil, err
+	}
+	return solution, nil
+}
+
+// ModelSolutionGet implements GET /admin/model_solutions/:id
+func (g *Group) ModelSolutionGet(c echo.Context) error {
+	solution, err := getModelSolution(g.db, c)
+	if err != nil {
+		return err
+	}
+	http.ServeContent(c.Response(), c.Request(), solution.Name, time.Now(), bytes.NewReader(solution.Source))
+	return nil
+}
+
+// ModelSolutionDelete implements POST /admin/model_solutions/:id/delete
+func (g *Group) ModelSolutionDelete(c echo.Context) error {
+	solution, err := getModelSolution(g.db, c)
+	if err != nil {
+		return err
+	}
+	if err := solution.Delete(g.db); err != nil {
+		return err
+	}
+	return c.Redirect(http.StatusSeeOther, fmt.Sprintf("/admin/problems/%d#model-solutions", solution.ProblemID))
+}
+
+// ProblemInvokePost implements POST /admin/problems/:id/invoke
+// It queues judging all model solutions of the problem against its tests, and redirects to the task.
+func (g *Group) ProblemInvokePost(c echo.Context) error {
+	ctx, err := g.getProblem(c)
+	if err != nil {
+		return err
+	}
+	if ctx.Problem.OutputOnly {
+		return httperr.BadRequestf("Output-only problems have no programs to judge.")
+	}
+	if len(ctx.ModelSolutions) == 0 {
+		return httperr.BadRequestf("The problem has no model solutions.")
+	}
+	task := models.NewAdminTask(ctx.Problem.ID, models.AdminTaskTypeInvoke, sql.NullInt64{})
+	if err := models.StartAdminTask(g.db, task); err != nil {
+		return err
+	}
+	return c.Redirect(http.StatusSeeOther, fmt.Sprintf("/admin/tasks/%d", task.ID))
+}
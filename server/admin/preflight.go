@@ -0,0 +1,47 @@
+package admin
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/natsukagami/kjudge/server/httperr"
+	"github.com/natsukagami/kjudge/worker"
+)
+
+// ContestPreflightGet implements GET /admin/contests/:id/preflight
+func (g *Group) ContestPreflightGet(c echo.Context) error {
+	ctx, err := getContest(g.db, c)
+	if err != nil {
+		return err
+	}
+	report, err := worker.Preflight(g.db, ctx.Contest)
+	if err != nil {
+		return err
+	}
+	return c.Render(http.StatusOK, "admin/contest_preflight", report)
+}
+
+// ContestReleaseHoldPost implements POST /admin/contests/:id/release_hold
+// The contest is checked again, and starts if no errors are found.
+func (g *Group) ContestReleaseHoldPost(c echo.Context) error {
+	ctx, err := getContest(g.db, c)
+	if err != nil {
+		return err
+	}
+	if !ctx.Contest.Held {
+		return httperr.BadRequestf("The contest is not held.")
+	}
+	report, err := worker.Preflight(g.db, ctx.Contest)
+	if err != nil {
+		return err
+	}
+	if errs := report.Errors(); len(errs) > 0 {
+		return httperr.BadRequestf("The pre-flight check still finds %d error(s).", len(errs))
+	}
+	if err := ctx.Contest.ReleaseHold(g.db, time.Now()); err != nil {
+		return err
+	}
+	return c.Redirect(http.StatusSeeOther, fmt.Sprintf("/admin/contests/%d", ctx.Contest.ID))
+}
@@ -11,6 +11,7 @@ import (
 	"github.com/natsukagami/kjudge/db"
 	"github.com/natsukagami/kjudge/models"
 	"github.com/natsukagami/kjudge/server/httperr"
+	"github.com/natsukagami/kjudge/worker"
 	"github.com/pkg/errors"
 )
 
@@ -42,11 +43,14 @@ func (o *OptionalInt64) UnmarshalParam(src string) error {
 }
 
 type TestGroupForm struct {
-	MemoryLimit OptionalInt64          `form:"memory_limit"`
-	Name        string                 `form:"name"`
-	Score       float64                `form:"score"`
-	ScoringMode models.TestScoringMode `form:"scoring_mode"`
-	TimeLimit   OptionalInt64          `form:"time_limit"`
+	MemoryLimit      OptionalInt64              `form:"memory_limit"`
+	Name             string                     `form:"name"`
+	Score            float64                    `form:"score"`
+	ScoringMode      models.TestScoringMode     `form:"scoring_mode"`
+	TimeLimit        OptionalInt64              `form:"time_limit"`
+	Visibility       models.TestGroupVisibility `form:"visibility"`
+	Pretest          bool                       `form:"pretest"`
+	SkipAfterFailure bool                       `form:"skip_after_failure"`
 }
 
 // Bind binds the form's values to the TestGroup.
@@ -56,6 +60,9 @@ func (f *TestGroupForm) Bind(t *models.TestGroup) {
 	t.ScoringMode = f.ScoringMode
 	t.TimeLimit = f.TimeLimit.NullInt64
 	t.MemoryLimit = f.MemoryLimit.NullInt64
+	t.Visibility = f.Visibility
+	t.Pretest = f.Pretest
+	t.SkipAfterFailure = f.SkipAfterFailure
 }
 
 // Collect the ID and get the corresponding problem.
@@ -83,7 +90,55 @@ func (g *Group) getProblem(c echo.Context) (*ProblemCtx, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &ProblemCtx{Problem: problem, Contest: contest, TestGroups: tests, Files: files}, err
+	overrides, err := models.GetProblemProblemLanguageLimits(g.db, problem.ID)
+	if err != nil {
+		return nil, err
+	}
+	solutions, err := models.GetProblemModelSolutions(g.db, problem.ID)
+	if err != nil {
+		return nil, err
+	}
+	generateTask, err := models.GetLatestAdminTask(g.db, problem.ID, models.AdminTaskTypeGenerate, sql.NullInt64{})
+	if err != nil {
+		return nil, err
+	}
+	validateTask, err := models.GetLatestAdminTask(g.db, problem.ID, models.AdminTaskTypeValidate, sql.NullInt64{})
+	if err != nil {
+		return nil, err
+	}
+	invokeTask, err := models.GetLatestAdminTask(g.db, problem.ID, models.AdminTaskTypeInvoke, sql.NullInt64{})
+	if err != nil {
+		return nil, err
+	}
+	hasValidator := false
+	for _, f := range files {
+		if f.Filename == worker.ValidatorFilename {
+			hasValidator = true
+		}
+	}
+	return &ProblemCtx{
+		Problem:        problem,
+		Contest:        contest,
+		TestGroups:     tests,
+		Files:          files,
+		LanguageLimits: languageLimitRows(overrides),
+		HasValidator:   hasValidator,
+		Validation:     models.SummarizeValidation(tests),
+		ValidateTask:   validateTask,
+		ModelSolutions: solutions,
+		InvokeTask:     invokeTask,
+		GenerateTask:   generateTask,
+		TestGroupForm: TestGroupForm{
+			SkipAfterFailure: true,
+		},
+		LanguageLimitForm: LanguageLimitForm{
+			TimeMultiplier:   1,
+			MemoryMultiplier: 1,
+		},
+		ModelSolutionForm: ModelSolutionForm{
+			Expected: models.ExpectedOutcomeAccepted,
+		},
+	}, nil
 }
 
 // ProblemCtx is the context for rendering admin/problem.
@@ -92,6 +147,19 @@ type ProblemCtx struct {
 	Contest    *models.Contest
 	TestGroups []*models.TestGroupWithTests
 	Files      []*models.File
+	// The limits adjustment of every language
+	LanguageLimits []*LanguageLimitRow
+	// Whether the problem has a validator, and the results of validating its tests.
+	HasValidator bool
+	Validation   *models.ValidationSummary
+	// The latest validation of all tests, nil if there is none.
+	ValidateTask *models.AdminTask
+	// The model solutions, judged on demand
+	ModelSolutions []*models.ModelSolution
+	// The latest judging of the model solutions, nil if there is none.
+	InvokeTask *models.AdminTask
+	// The latest generation of the outputs of all tests, nil if there is none.
+	GenerateTask *models.AdminTask
 
 	// Edit Problem Form
 	EditForm      ProblemForm
@@ -100,6 +168,14 @@ type ProblemCtx struct {
 	// New TestGroup form
 	TestGroupForm      TestGroupForm
 	TestGroupFormError error
+
+	// New language limit form
+	LanguageLimitForm      LanguageLimitForm
+	LanguageLimitFormError error
+
+	// New model solution form
+	ModelSolutionForm      ModelSolutionForm
+	ModelSolutionFormError error
 }
 
 // ProblemGet implements GET /admin/problems/:id
@@ -115,7 +191,8 @@ func (g *Group) ProblemGet(c echo.Context) error {
 // Render the context.
 func (g *Group) problemRender(ctx *ProblemCtx, c echo.Context) error {
 	status := http.StatusOK
-	if ctx.EditFormError != nil || ctx.TestGroupFormError != nil {
+	if ctx.EditFormError != nil || ctx.TestGroupFormError != nil || ctx.LanguageLimitFormError != nil ||
+		ctx.ModelSolutionFormError != nil {
 		status = http.StatusBadRequest
 	}
 	return c.Render(status, "admin/problem", ctx)
@@ -145,6 +222,8 @@ func (g *Group) ProblemAddTestGroup(c echo.Context) error {
 	if err != nil {
 		return err
 	}
+	// Bind into an empty form, so that the unchecked checkboxes do not keep their defaults.
+	ctx.TestGroupForm = TestGroupForm{}
 	if err := c.Bind(&ctx.TestGroupForm); err != nil {
 		return err
 	}
@@ -206,6 +285,14 @@ func (g *Group) ProblemAddFile(c echo.Context) error {
 	if err := ctx.Problem.WriteFiles(g.db, files); err != nil {
 		return httperr.BadRequestf("cannot write files: %v", err)
 	}
+	for _, f := range files {
+		if f.Filename == worker.ValidatorFilename {
+			// The tests need to be validated again.
+			if err := models.ResetProblemTestValidation(g.db, ctx.Problem.ID); err != nil {
+				return err
+			}
+		}
+	}
 	return c.Redirect(http.StatusSeeOther, fmt.Sprintf("/admin/problems/%d#files", ctx.Problem.ID))
 }
 
@@ -249,3 +336,12 @@ func (g *Group) ProblemRejudgePost(c echo.Context) error {
 	}
 	return c.Redirect(http.StatusSeeOther, fmt.Sprintf("/admin/problems/%d/submissions", p.Problem.ID))
 }
+
+// ProblemGenerateOutputsPost implements POST /admin/problems/:id/generate_outputs
+func (g *Group) ProblemGenerateOutputsPost(c echo.Context) error {
+	ctx, err := g.getProblem(c)
+	if err != nil {
+		return err
+	}
+	return g.generateOutputs(c, ctx.Problem, sql.NullInt64{})
+}
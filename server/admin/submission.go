@@ -22,8 +22,15 @@ type SubmissionCtx struct {
 	Contest     *models.Contest
 	TestGroups  []*models.TestGroupWithTests
 	TestResults map[int]*models.TestResult
+	// Whether the submission is only judged on the pretests.
+	Pretests bool
+	// The beginning of each test's expected output, shown next to the submission's output.
+	ExpectedOutputs map[int][]byte
 }
 
+// The length of the expected outputs shown on the submission page, in bytes.
+const expectedOutputPreviewLength = 8 * 1024
+
 // Render renders the context.
 func (s *SubmissionCtx) Render(c echo.Context) error {
 	return c.Render(http.StatusOK, "admin/submission", s)
@@ -67,8 +74,17 @@ func getSubmissionCtx(db db.DBContext, c echo.Context) (*SubmissionCtx, error) {
 		for _, tr := range testResults {
 			trMap[tr.TestID] = tr
 		}
+		expected, err := models.GetProblemTestOutputPreviews(db, problem.ID, expectedOutputPreviewLength)
+		if err != nil {
+			return nil, err
+		}
+		if sub.ScoredOnPretests(contest) {
+			testGroups = models.PretestGroups(testGroups)
+			ctx.Pretests = true
+		}
 		ctx.TestGroups = testGroups
 		ctx.TestResults = trMap
+		ctx.ExpectedOutputs = expected
 	}
 
 	return ctx, nil
@@ -103,7 +119,8 @@ func (g *Group) SubmissionVerdictGet(c echo.Context) error {
 	if err != nil {
 		return err
 	}
-	if ctx.Submission.Verdict == models.VerdictIsInQueue || ctx.Submission.Verdict == models.VerdictCompileError {
+	if ctx.Submission.Verdict == models.VerdictIsInQueue || ctx.Submission.Verdict == models.VerdictCompileError ||
+		ctx.Submission.Verdict == models.VerdictJudgeError {
 		return c.JSON(http.StatusOK, map[string]interface{}{
 			"verdict": ctx.Submission.Verdict,
 		})
@@ -15,6 +15,7 @@ import (
 	"github.com/natsukagami/kjudge/models"
 	"github.com/natsukagami/kjudge/server/httperr"
 	"github.com/natsukagami/kjudge/tests"
+	"github.com/natsukagami/kjudge/worker"
 	"github.com/pkg/errors"
 )
 
@@ -23,6 +24,15 @@ type TestGroupCtx struct {
 	*models.TestGroupWithTests
 	Contest *models.Contest
 	Problem *models.Problem
+	// The other test groups of the problem, which the test group can depend on.
+	OtherTestGroups []*models.TestGroup
+	// Whether the problem has a validator, and the results of validating the test group's tests.
+	HasValidator bool
+	Validation   *models.ValidationSummary
+	// The latest validation of the test group's tests, nil if there is none.
+	ValidateTask *models.AdminTask
+	// The latest generation of the outputs of the test group's tests, nil if there is none.
+	GenerateTask *models.AdminTask
 }
 
 func getTestGroup(db db.DBContext, c echo.Context) (*TestGroupCtx, error) {
@@ -49,14 +59,46 @@ func getTestGroup(db db.DBContext, c echo.Context) (*TestGroupCtx, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &TestGroupCtx{
+	testGroups, err := models.GetProblemTestGroups(db, problem.ID)
+	if err != nil {
+		return nil, err
+	}
+	validator, err := models.GetFileWithName(db, problem.ID, worker.ValidatorFilename)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	generateTask, err := models.GetLatestAdminTask(db, problem.ID, models.AdminTaskTypeGenerate,
+		sql.NullInt64{Int64: int64(tg.ID), Valid: true})
+	if err != nil {
+		return nil, err
+	}
+	validateTask, err := models.GetLatestAdminTask(db, problem.ID, models.AdminTaskTypeValidate,
+		sql.NullInt64{Int64: int64(tg.ID), Valid: true})
+	if err != nil {
+		return nil, err
+	}
+	ctx := &TestGroupCtx{
 		TestGroupWithTests: &models.TestGroupWithTests{
 			TestGroup: tg,
 			Tests:     tests,
 		},
-		Problem: problem,
-		Contest: contest,
-	}, nil
+		Problem:      problem,
+		Contest:      contest,
+		HasValidator: validator != nil,
+		GenerateTask: generateTask,
+		ValidateTask: validateTask,
+	}
+	ctx.Validation = models.SummarizeValidation([]*models.TestGroupWithTests{ctx.TestGroupWithTests})
+	for _, other := range testGroups {
+		if other.ID == tg.ID {
+			continue
+		}
+		ctx.OtherTestGroups = append(ctx.OtherTestGroups, other)
+		if tg.Dependencies.Has(other.ID) {
+			ctx.Dependencies = append(ctx.Dependencies, &models.TestGroupWithTests{TestGroup: other})
+		}
+	}
+	return ctx, nil
 }
 
 // Render renders the context.
@@ -67,11 +109,14 @@ func (ctx *TestGroupCtx) Render(c echo.Context) error {
 // ToForm converts the context into a nicer form format.
 func (ctx *TestGroupCtx) ToForm() TestGroupForm {
 	return TestGroupForm{
-		Name:        ctx.Name,
-		Score:       ctx.Score,
-		ScoringMode: ctx.ScoringMode,
-		MemoryLimit: OptionalInt64{ctx.MemoryLimit},
-		TimeLimit:   OptionalInt64{ctx.TimeLimit},
+		Name:             ctx.Name,
+		Score:            ctx.Score,
+		ScoringMode:      ctx.ScoringMode,
+		MemoryLimit:      OptionalInt64{ctx.MemoryLimit},
+		TimeLimit:        OptionalInt64{ctx.TimeLimit},
+		Visibility:       ctx.Visibility,
+		Pretest:          ctx.Pretest,
+		SkipAfterFailure: ctx.SkipAfterFailure,
 	}
 }
 
@@ -105,9 +150,16 @@ func (g *Group) TestGroupUploadSingle(c echo.Context) error {
 	if err != nil {
 		return err
 	}
-	output, err := readFromForm("output", mp)
-	if err != nil {
-		return err
+	// With only the input, the output is left empty to be generated by the reference solution.
+	inputsOnly := c.FormValue("inputs_only") == "true"
+	if inputsOnly && tg.Problem.ReferenceSolution == "" {
+		return httperr.BadRequestf("The problem has no reference solution to generate the output with.")
+	}
+	output := []byte{}
+	if !inputsOnly {
+		if output, err = readFromForm("output", mp); err != nil {
+			return err
+		}
 	}
 	// Make the test
 	test := &models.Test{
@@ -122,6 +174,12 @@ func (g *Group) TestGroupUploadSingle(c echo.Context) error {
 	if err := tx.Commit(); err != nil {
 		return errors.WithStack(err)
 	}
+	if err := g.validateNewTests(tg.ProblemID, sql.NullInt64{Int64: int64(tg.ID), Valid: true}); err != nil {
+		return err
+	}
+	if inputsOnly {
+		return g.generateOutputs(c, tg.Problem, sql.NullInt64{Int64: int64(tg.ID), Valid: true})
+	}
 	return c.Redirect(http.StatusSeeOther, fmt.Sprintf("/admin/test_groups/%d", tg.ID))
 }
 
@@ -138,6 +196,10 @@ func (g *Group) TestGroupUploadMultiple(c echo.Context) error {
 	}
 
 	override := c.FormValue("override") == "true"
+	inputsOnly := c.FormValue("inputs_only") == "true"
+	if inputsOnly && tg.Problem.ReferenceSolution == "" {
+		return httperr.BadRequestf("The problem has no reference solution to generate the outputs with.")
+	}
 	mp, err := c.MultipartForm()
 	if err != nil {
 		return httperr.BindFail(err)
@@ -146,16 +208,27 @@ func (g *Group) TestGroupUploadMultiple(c echo.Context) error {
 	if err != nil {
 		return err
 	}
-	tests, err := tests.Unpack(bytes.NewReader(file), int64(len(file)), c.FormValue("input"), c.FormValue("output"))
+	var lazyTests []*tests.LazyTest
+	if inputsOnly {
+		lazyTests, err = tests.UnpackInputs(bytes.NewReader(file), int64(len(file)), c.FormValue("input"))
+	} else {
+		lazyTests, err = tests.Unpack(bytes.NewReader(file), int64(len(file)), c.FormValue("input"), c.FormValue("output"))
+	}
 	if err != nil {
 		return httperr.BadRequestf("cannot unpack tests: %v", err)
 	}
-	if err := tg.WriteTests(tx, tests, override); err != nil {
+	if err := tg.WriteTests(tx, lazyTests, override, inputsOnly); err != nil {
 		return httperr.BadRequestf("Cannot write tests: %v", err)
 	}
 	if err := tx.Commit(); err != nil {
 		return err
 	}
+	if err := g.validateNewTests(tg.ProblemID, sql.NullInt64{Int64: int64(tg.ID), Valid: true}); err != nil {
+		return err
+	}
+	if inputsOnly {
+		return g.generateOutputs(c, tg.Problem, sql.NullInt64{Int64: int64(tg.ID), Valid: true})
+	}
 	return c.Redirect(http.StatusSeeOther, fmt.Sprintf("/admin/test_groups/%d", tg.ID))
 }
 
@@ -182,12 +255,68 @@ func (g *Group) TestGroupDelete(c echo.Context) error {
 	if err != nil {
 		return err
 	}
-	if err := tg.Delete(g.db); err != nil {
+	tx, err := g.db.Beginx()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer db.Rollback(tx)
+	if err := models.RemoveTestGroupDependency(tx, tg.TestGroup); err != nil {
 		return err
 	}
+	if err := tg.Delete(tx); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return errors.WithStack(err)
+	}
 	return c.Redirect(http.StatusSeeOther, fmt.Sprintf("/admin/problems/%d", tg.ProblemID))
 }
 
+// TestGroupDependenciesForm is a form for setting the dependencies of a test group.
+type TestGroupDependenciesForm struct {
+	Dependencies []int `form:"dependencies"`
+}
+
+// TestGroupDependenciesPost implements POST /admin/test_groups/:id/dependencies
+func (g *Group) TestGroupDependenciesPost(c echo.Context) error {
+	tg, err := getTestGroup(g.db, c)
+	if err != nil {
+		return err
+	}
+	var form TestGroupDependenciesForm
+	if err := c.Bind(&form); err != nil {
+		return httperr.BindFail(err)
+	}
+	tx, err := g.db.Beginx()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer db.Rollback(tx)
+	tg.TestGroup.Dependencies = form.Dependencies
+	if err := tg.VerifyDependencies(tx); err != nil {
+		return httperr.BadRequestf("Cannot update the dependencies: %v", err)
+	}
+	if err := tg.Write(tx); err != nil {
+		return httperr.BadRequestf("Cannot update the dependencies: %v", err)
+	}
+	// The scores of the submissions change, but not their test results.
+	subs, err := models.GetProblemSubmissions(tx, tg.ProblemID)
+	if err != nil {
+		return err
+	}
+	var id []int
+	for _, sub := range subs {
+		id = append(id, sub.ID)
+	}
+	if err := models.RejudgeScore(tx, id...); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return errors.WithStack(err)
+	}
+	return c.Redirect(http.StatusSeeOther, fmt.Sprintf("/admin/test_groups/%d", tg.ID))
+}
+
 func readFromForm(name string, form *multipart.Form) ([]byte, error) {
 	file, ok := form.File[name]
 	if !ok {
@@ -241,10 +370,20 @@ func (g *Group) TestGroupRejudgePost(c echo.Context) error {
 	return c.Redirect(http.StatusSeeOther, fmt.Sprintf("/admin/problems/%d/submissions", tg.ProblemID))
 }
 
+// TestGroupGenerateOutputsPost implements POST /admin/test_groups/:id/generate_outputs
+func (g *Group) TestGroupGenerateOutputsPost(c echo.Context) error {
+	ctx, err := getTestGroup(g.db, c)
+	if err != nil {
+		return err
+	}
+	return g.generateOutputs(c, ctx.Problem, sql.NullInt64{Int64: int64(ctx.ID), Valid: true})
+}
+
 // WriteTests writes the given set of tests into the Database.
 // If override is set, all tests in the test group gets deleted first.
+// If inputsOnly is set, the tests only come with their inputs (see tests.UnpackInputs), and get empty outputs.
 // The LazyTests are STILL invalid models.Tests. DO NOT USE.
-func (r *TestGroupCtx) WriteTests(db db.DBContext, tests []*tests.LazyTest, override bool) error {
+func (r *TestGroupCtx) WriteTests(db db.DBContext, tests []*tests.LazyTest, override, inputsOnly bool) error {
 	for _, test := range tests {
 		test.TestGroupID = r.ID
 		if err := test.Verify(); err != nil {
@@ -261,9 +400,14 @@ func (r *TestGroupCtx) WriteTests(db db.DBContext, tests []*tests.LazyTest, over
 		if err != nil {
 			return errors.Wrapf(err, "test %v input", test.Name)
 		}
-		output, err := readZip(test.Output)
-		if err != nil {
-			return errors.Wrapf(err, "test %v output", test.Name)
+		output := []byte{}
+		if !inputsOnly {
+			if test.Output == nil {
+				return errors.Errorf("test %v has no output", test.Name)
+			}
+			if output, err = readZip(test.Output); err != nil {
+				return errors.Wrapf(err, "test %v output", test.Name)
+			}
 		}
 		if _, err := db.Exec(
 			"INSERT INTO tests(name, test_group_id, input, output) VALUES (?, ?, ?, ?)",
@@ -0,0 +1,82 @@
+package admin
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/natsukagami/kjudge/db"
+	"github.com/natsukagami/kjudge/models"
+	"github.com/natsukagami/kjudge/server/httperr"
+	"github.com/natsukagami/kjudge/worker"
+	"github.com/pkg/errors"
+)
+
+// ValidateCtx is the context for rendering admin/validate_report.
+type ValidateCtx struct {
+	*AdminTaskCtx
+	// The report, nil until the task is done.
+	*worker.ValidateReport
+}
+
+// Queues validating the tests that are not validated yet (of the test group if it is valid), if the problem has
+// a validator. It is done right after the tests are uploaded.
+func (g *Group) validateNewTests(problemID int, testGroupID sql.NullInt64) error {
+	validator, err := worker.GetValidator(g.db, problemID)
+	if err != nil || validator == nil {
+		return err
+	}
+	return models.StartAdminTask(g.db, models.NewAdminTask(problemID, models.AdminTaskTypeValidate, testGroupID))
+}
+
+// Marks all tests of the problem (limited to the test group if it is valid) as not validated, queues validating them
+// with the problem's validator, and redirects to the task.
+func (g *Group) validateAllTests(c echo.Context, problemID int, testGroupID sql.NullInt64) error {
+	validator, err := worker.GetValidator(g.db, problemID)
+	if err != nil {
+		return err
+	}
+	if validator == nil {
+		return httperr.BadRequestf("The problem has no `%s` file.", worker.ValidatorFilename)
+	}
+	tx, err := g.db.Beginx()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer db.Rollback(tx)
+	if testGroupID.Valid {
+		err = models.ResetTestGroupTestValidation(tx, int(testGroupID.Int64))
+	} else {
+		err = models.ResetProblemTestValidation(tx, problemID)
+	}
+	if err != nil {
+		return err
+	}
+	task := models.NewAdminTask(problemID, models.AdminTaskTypeValidate, testGroupID)
+	if err := models.StartAdminTask(tx, task); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return errors.WithStack(err)
+	}
+	return c.Redirect(http.StatusSeeOther, fmt.Sprintf("/admin/tasks/%d", task.ID))
+}
+
+// ProblemValidatePost implements POST /admin/problems/:id/validate
+func (g *Group) ProblemValidatePost(c echo.Context) error {
+	ctx, err := g.getProblem(c)
+	if err != nil {
+		return err
+	}
+	return g.validateAllTests(c, ctx.Problem.ID, sql.NullInt64{})
+}
+
+// TestGroupValidatePost implements POST /admin/test_groups/:id/validate
+func (g *Group) TestGroupValidatePost(c echo.Context) error {
+	ctx, err := getTestGroup(g.db, c)
+	if err != nil {
+		return err
+	}
+	return g.validateAllTests(c, ctx.ProblemID, sql.NullInt64{Int64: int64(ctx.ID), Valid: true})
+}
@@ -3,12 +3,14 @@ package contests
 import (
 	"database/sql"
 	"strconv"
+	"time"
 
 	"github.com/labstack/echo/v4"
 	"github.com/natsukagami/kjudge/db"
 	"github.com/natsukagami/kjudge/models"
 	"github.com/natsukagami/kjudge/server/httperr"
 	"github.com/natsukagami/kjudge/server/user"
+	"github.com/natsukagami/kjudge/worker"
 	"github.com/pkg/errors"
 )
 
@@ -18,6 +20,36 @@ type ContestCtx struct {
 
 	Contest  *models.Contest
 	Problems []*models.Problem
+	// Whether the start of the contest is held by the errors of its pre-flight check.
+	Held bool
+}
+
+// Started returns whether the contest has started, and is not held.
+func (c *ContestCtx) Started() bool {
+	return !c.Held && !c.Contest.StartTime.After(time.Now())
+}
+
+// Returns whether the started contest is held by the errors of its pre-flight check (see worker.Preflight).
+// The check is only run once, the first time the contest is seen started; the admins release the hold afterwards.
+func contestHeld(db db.DBContext, contest *models.Contest) (bool, error) {
+	now := time.Now()
+	if !contest.PreflightHold || contest.StartTime.After(now) {
+		return false, nil
+	}
+	if contest.HoldChecked {
+		return contest.Held, nil
+	}
+	if contest.EndTime.Before(now) {
+		return false, nil
+	}
+	report, err := worker.Preflight(db, contest)
+	if err != nil {
+		return false, err
+	}
+	if err := contest.RecordHold(db, report.Held()); err != nil {
+		return false, err
+	}
+	return contest.Held, nil
 }
 
 // Collect a contestctx from the echo Context.
@@ -41,9 +73,14 @@ func getContestCtx(db db.DBContext, c echo.Context) (*ContestCtx, error) {
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
+	held, err := contestHeld(db, contest)
+	if err != nil {
+		return nil, err
+	}
 	return &ContestCtx{
 		AuthCtx:  me,
 		Contest:  contest,
 		Problems: problems,
+		Held:     held,
 	}, nil
 }
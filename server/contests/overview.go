@@ -2,6 +2,7 @@ package contests
 
 import (
 	"net/http"
+	"time"
 
 	"github.com/labstack/echo/v4"
 	"github.com/natsukagami/kjudge/db"
@@ -27,7 +28,7 @@ func getOverviewCtx(db db.DBContext, c echo.Context) (*OverviewCtx, error) {
 	if err != nil {
 		return nil, err
 	}
-	problems, err := models.CollectTestGroups(db, contest.Problems, false)
+	problems, err := models.CollectTestGroups(db, contest.Problems)
 	if err != nil {
 		return nil, err
 	}
@@ -35,6 +36,14 @@ func getOverviewCtx(db db.DBContext, c echo.Context) (*OverviewCtx, error) {
 	if err != nil {
 		return nil, err
 	}
+	ended := contest.Contest.EndTime.Before(time.Now())
+	for _, p := range contest.Problems {
+		if feedbackLevel(contest.Contest, p) == models.FeedbackLevelNone {
+			delete(scores, p.ID)
+		} else if score, ok := scores[p.ID]; ok && !ended {
+			scores[p.ID] = score.DuringContest()
+		}
+	}
 	return &OverviewCtx{
 		ContestCtx: contest,
 		Problems:   problems,
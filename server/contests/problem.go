@@ -8,12 +8,15 @@ import (
 	"net/http"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/labstack/echo/v4"
 	"github.com/natsukagami/kjudge/db"
 	"github.com/natsukagami/kjudge/models"
 	"github.com/natsukagami/kjudge/server/httperr"
+	"github.com/natsukagami/kjudge/tests"
+	"github.com/natsukagami/kjudge/worker/sandbox"
 	"github.com/pkg/errors"
 )
 
@@ -24,6 +27,19 @@ type ProblemCtx struct {
 	Problem     *models.Problem
 	Files       map[string]*models.File
 	Submissions []*models.Submission
+	// The languages submissions are accepted in.
+	Languages []*models.LanguageSpec
+	// Whether the problem is graded with a grader.
+	HasGraders bool
+	// The limits for each accepted language, if they are adjusted for any of them.
+	LanguageLimits []*LanguageLimits
+}
+
+// LanguageLimits are the effective limits of a problem for a language.
+type LanguageLimits struct {
+	Language    *models.LanguageSpec
+	TimeLimit   int // In milliseconds
+	MemoryLimit int // In KBs
 }
 
 // Render renders the context.
@@ -39,7 +55,7 @@ func getProblemCtx(db db.DBContext, c echo.Context) (*ProblemCtx, error) {
 	}
 
 	// If the contest has not started, throw
-	if contest.Contest.StartTime.After(time.Now()) {
+	if !contest.Started() {
 		return nil, httperr.BadRequestf("Contest has not started")
 	}
 
@@ -60,18 +76,76 @@ func getProblemCtx(db db.DBContext, c echo.Context) (*ProblemCtx, error) {
 			fm[f.Filename] = f
 		}
 	}
+	languages := problem.Languages.Filter(contest.Contest.Languages.Filter(models.AvailableLanguages()))
+	graders := models.Graders(files)
+	if len(graders) > 0 {
+		// Only the languages with a grader can be accepted.
+		var withGraders []*models.LanguageSpec
+		for _, l := range languages {
+			if _, ok := graders[l.ID]; ok && l.SupportsGraders() {
+				withGraders = append(withGraders, l)
+			}
+		}
+		languages = withGraders
+	}
+	overrides, err := models.GetProblemProblemLanguageLimits(db, problem.ID)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		limits   []*LanguageLimits
+		adjusted bool
+	)
+	for _, l := range languages {
+		ll := models.LanguageLimitsOf(l.ID, overrides)
+		adjusted = adjusted || !ll.IsIdentity()
+		limits = append(limits, &LanguageLimits{
+			Language:    l,
+			TimeLimit:   int(ll.TimeLimit(time.Duration(problem.TimeLimit)*time.Millisecond) / time.Millisecond),
+			MemoryLimit: ll.MemoryLimit(problem.MemoryLimit),
+		})
+	}
+	if !adjusted {
+		limits = nil
+	}
 	subs, err := models.GetUserProblemSubmissions(db, contest.Me.ID, problem.ID)
 	if err != nil {
 		return nil, err
 	}
+	for i, sub := range subs {
+		subs[i] = contestantSubmission(contest.Contest, problem, sub)
+	}
 	return &ProblemCtx{
 		ContestCtx:  contest,
 		Problem:     problem,
 		Files:       fm,
 		Submissions: subs,
+
+		Languages:      languages,
+		HasGraders:     len(graders) > 0,
+		LanguageLimits: limits,
 	}, nil
 }
 
+// AcceptsLanguage returns whether submissions in the language are accepted.
+func (p *ProblemCtx) AcceptsLanguage(l models.Language) bool {
+	for _, al := range p.Languages {
+		if al.ID == l {
+			return true
+		}
+	}
+	return false
+}
+
+// LanguageNames returns the names of the accepted languages.
+func (p *ProblemCtx) LanguageNames() string {
+	names := make([]string, len(p.Languages))
+	for i, l := range p.Languages {
+		names[i] = l.Name
+	}
+	return strings.Join(names, ", ")
+}
+
 // ProblemGet implements GET /contest/:id/problems/:problem
 func (g *Group) ProblemGet(c echo.Context) error {
 	ctx, err := getProblemCtx(g.db, c)
@@ -145,10 +219,6 @@ func (g *Group) SubmitPost(c echo.Context) error {
 		return httperr.BadRequestf("One file must be attached")
 	}
 	file := files[0]
-	lang, err := models.LanguageByExt(filepath.Ext(file.Filename))
-	if err != nil {
-		return httperr.BadRequestf("Cannot resolve language: %v", err)
-	}
 	fileContent, err := file.Open()
 	if err != nil {
 		return errors.WithStack(err)
@@ -162,10 +232,26 @@ func (g *Group) SubmitPost(c echo.Context) error {
 		ProblemID:   ctx.Problem.ID,
 		UserID:      ctx.Me.ID,
 		Source:      source,
-		Language:    lang,
 		SubmittedAt: now,
 		Verdict:     models.VerdictIsInQueue,
 	}
+	if ctx.Problem.OutputOnly {
+		if err := ctx.readOutputs(tx, &sub, file.Filename); err != nil {
+			return err
+		}
+	} else {
+		lang, err := models.LanguageByExt(filepath.Ext(file.Filename))
+		if err != nil {
+			return httperr.BadRequestf("Cannot resolve language: %v", err)
+		}
+		if !lang.Spec().Available() {
+			return httperr.BadRequestf("Submissions in %s are not accepted anymore: the language is not available", lang.Name())
+		}
+		if !ctx.AcceptsLanguage(lang) {
+			return httperr.BadRequestf("Submissions in %s are not accepted for this problem. Accepted languages: %s", lang.Name(), ctx.LanguageNames())
+		}
+		sub.Language = lang
+	}
 
 	if err := sub.Write(tx); err != nil {
 		return err
@@ -181,3 +267,97 @@ func (g *Group) SubmitPost(c echo.Context) error {
 	}
 	return c.Redirect(http.StatusSeeOther, fmt.Sprintf("/contests/%d/problems/%s#submissions", ctx.Contest.ID, ctx.Problem.Name))
 }
+
+// Reads the outputs uploaded to the output-only problem into the submission, as an archive of its outputs.
+// The tests the upload has no output for keep the best output the contestant submitted before: the one scoring
+// the most on the test, or the latest one if none of them is judged yet.
+// Nothing needs to be compiled, so the archive is also the submission's compiled source.
+func (ctx *ProblemCtx) readOutputs(db db.DBContext, sub *models.Submission, filename string) error {
+	// The outputs are at most as large as the ones the judged programs may write.
+	uploaded, err := tests.UnpackOutputs(sub.Source, filename, ctx.Problem.OutputPattern, sandbox.DefaultOutputLimit*1024)
+	if err != nil {
+		return httperr.BadRequestf("Cannot read the outputs: %v", err)
+	}
+	testGroups, err := models.GetProblemTestsMeta(db, ctx.Problem.ID)
+	if err != nil {
+		return err
+	}
+	var problemTests []*models.Test
+	for _, tg := range testGroups {
+		problemTests = append(problemTests, tg.Tests...)
+	}
+	outputs := make(map[string][]byte)
+	var uploadedNames []string
+	for _, test := range problemTests {
+		if output, ok := uploaded[test.Name]; ok {
+			outputs[test.Name] = output
+			uploadedNames = append(uploadedNames, test.Name)
+		}
+	}
+	if len(outputs) == 0 {
+		return httperr.BadRequestf("None of the files are outputs of the tests. The outputs must be named like %s, "+
+			"with the question mark standing for the test's name", ctx.Problem.OutputPattern)
+	}
+
+	// Look for the best previous output of each missing test, from the latest submission to the earliest.
+	type keptOutput struct {
+		output []byte
+		score  float64
+		judged bool
+		subID  int
+	}
+	kept := make(map[string]*keptOutput)
+	previous, err := models.GetUserProblemSubmissions(db, ctx.Me.ID, ctx.Problem.ID)
+	if err != nil {
+		return err
+	}
+	for _, prev := range previous {
+		if !prev.OutputOnly() {
+			continue
+		}
+		prevOutputs, err := tests.ReadOutputs(prev.Source)
+		if err != nil {
+			return err
+		}
+		results, err := models.GetSubmissionTestResults(db, prev.ID)
+		if err != nil {
+			return err
+		}
+		resultOf := make(map[int]*models.TestResult)
+		for _, r := range results {
+			resultOf[r.TestID] = r
+		}
+		for _, test := range problemTests {
+			output, ok := prevOutputs[test.Name]
+			if _, uploaded := outputs[test.Name]; uploaded || !ok {
+				continue
+			}
+			candidate := &keptOutput{output: output, subID: prev.ID}
+			if r, ok := resultOf[test.ID]; ok && r.VerdictCode != models.VerdictCodeSkipped {
+				candidate.score, candidate.judged = r.Score, true
+			}
+			current, ok := kept[test.Name]
+			if !ok || (candidate.judged && (!current.judged || candidate.score > current.score)) {
+				kept[test.Name] = candidate
+			}
+		}
+	}
+	var message strings.Builder
+	fmt.Fprintf(&message, "Uploaded the outputs of %d test(s): %s\n", len(uploadedNames), strings.Join(uploadedNames, ", "))
+	for _, test := range problemTests {
+		if k, ok := kept[test.Name]; ok {
+			outputs[test.Name] = k.output
+			fmt.Fprintf(&message, "Kept the output of test %s from submission #%d\n", test.Name, k.subID)
+		}
+	}
+
+	archive, err := tests.PackOutputs(outputs)
+	if err != nil {
+		return err
+	}
+	sub.Language = models.LanguageOutputOnly
+	sub.Source = archive
+	sub.CompiledSource = archive
+	sub.CompilerOutput = []byte(message.String())
+	return nil
+}
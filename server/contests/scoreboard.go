@@ -17,11 +17,18 @@ import (
 type ScoreboardCtx struct {
 	*user.AuthCtx
 	*models.Scoreboard
+	// Whether the start of the contest is held by the errors of its pre-flight check.
+	Held bool
+}
+
+// Started returns whether the contest has started, and is not held.
+func (s *ScoreboardCtx) Started() bool {
+	return !s.Held && !s.Contest.StartTime.After(time.Now())
 }
 
 // Show decides whether the scoreboard can be shown.
 func (s *ScoreboardCtx) Show() error {
-	if s.Contest.StartTime.After(time.Now()) {
+	if !s.Started() {
 		return httperr.BadRequestf("Contest has not started")
 	}
 	if s.Contest.EndTime.Before(time.Now()) {
@@ -70,7 +77,7 @@ func getScoreboardCtx(db db.DBContext, c echo.Context) (*ScoreboardCtx, error) {
 	// get contest information
 	problems := contestCtx.Problems
 
-	scoreboard, err := models.GetScoreboard(db, contest, problems)
+	scoreboard, err := models.GetContestantScoreboard(db, contest, problems)
 	if err != nil {
 		return nil, err
 	}
@@ -78,6 +85,7 @@ func getScoreboardCtx(db db.DBContext, c echo.Context) (*ScoreboardCtx, error) {
 	return &ScoreboardCtx{
 		AuthCtx:    contestCtx.AuthCtx,
 		Scoreboard: scoreboard,
+		Held:       contestCtx.Held,
 	}, nil
 }
 
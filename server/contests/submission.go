@@ -2,8 +2,10 @@ package contests
 
 import (
 	"database/sql"
+	"fmt"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/labstack/echo/v4"
 	"github.com/natsukagami/kjudge/db"
@@ -20,6 +22,14 @@ type SubmissionCtx struct {
 	Problem     *models.Problem
 	TestGroups  []*models.TestGroupWithTests
 	TestResults map[int]*models.TestResult
+	// Whether the submission is only judged on the pretests.
+	Pretests bool
+
+	// How much of the results is shown, see models.FeedbackLevel.
+	Feedback models.FeedbackLevel
+	// The first test that is not accepted and its result, with the FirstFailure feedback level.
+	FailedTest   *models.Test
+	FailedResult *models.TestResult
 }
 
 // Collect a submission ctx.
@@ -59,6 +69,11 @@ func getSubmissionCtx(db db.DBContext, c echo.Context) (*SubmissionCtx, error) {
 	if err != nil {
 		return nil, err
 	}
+	pretests := sub.ScoredOnPretests(contest.Contest)
+	if pretests {
+		testGroups = models.PretestGroups(testGroups)
+	}
+	testGroups = models.VisibleTestGroups(testGroups, contest.Contest.EndTime.Before(time.Now()))
 
 	var testResults map[int]*models.TestResult
 	if sub.Score.Valid {
@@ -72,13 +87,44 @@ func getSubmissionCtx(db db.DBContext, c echo.Context) (*SubmissionCtx, error) {
 		}
 	}
 
-	return &SubmissionCtx{
+	ctx := &SubmissionCtx{
 		ContestCtx:  contest,
 		Submission:  sub,
 		Problem:     problem,
 		TestGroups:  testGroups,
 		TestResults: testResults,
-	}, nil
+		Pretests:    pretests,
+	}
+	ctx.applyFeedback(feedbackLevel(contest.Contest, problem))
+	return ctx, nil
+}
+
+// Returns the feedback level of the problem's submissions, which is full once the contest has ended.
+func feedbackLevel(contest *models.Contest, problem *models.Problem) models.FeedbackLevel {
+	return problem.ContestantFeedback(contest)
+}
+
+// Returns the submission as the contestants see it: until the contest ends, scored on the test groups visible
+// during the contest only, and with the feedback level of the problem.
+func contestantSubmission(contest *models.Contest, problem *models.Problem, sub *models.Submission) *models.Submission {
+	if !contest.EndTime.Before(time.Now()) {
+		sub = sub.DuringContest()
+	}
+	return sub.WithFeedback(feedbackLevel(contest, problem))
+}
+
+// Strips the results the contestant should not see with the feedback level.
+func (ctx *SubmissionCtx) applyFeedback(level models.FeedbackLevel) {
+	ctx.Feedback = level
+	ctx.Submission = contestantSubmission(ctx.Contest, ctx.Problem, ctx.Submission)
+	switch level {
+	case models.FeedbackLevelFull, models.FeedbackLevelGroups:
+	case models.FeedbackLevelFirstFailure:
+		ctx.FailedTest, ctx.FailedResult = models.FirstFailedTest(ctx.TestGroups, ctx.TestResults)
+		ctx.TestGroups, ctx.TestResults = nil, nil
+	default:
+		ctx.TestGroups, ctx.TestResults = nil, nil
+	}
 }
 
 // Render renders the context.
@@ -101,6 +147,10 @@ func (g *Group) SubmissionDownload(c echo.Context) error {
 	if err != nil {
 		return err
 	}
+	if ctx.Submission.OutputOnly() {
+		c.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="outputs_s%d.zip"`, ctx.Submission.ID))
+		return c.Blob(http.StatusOK, "application/zip", ctx.Submission.Source)
+	}
 	return c.Blob(http.StatusOK, "text/plain", ctx.Submission.Source)
 }
 
@@ -110,7 +160,8 @@ func (g *Group) SubmissionVerdictGet(c echo.Context) error {
 	if err != nil {
 		return err
 	}
-	if ctx.Submission.Verdict == models.VerdictIsInQueue || ctx.Submission.Verdict == models.VerdictCompileError {
+	if ctx.Submission.Verdict == models.VerdictIsInQueue || ctx.Submission.Verdict == models.VerdictCompileError ||
+		ctx.Submission.Verdict == models.VerdictJudgeError || ctx.Submission.Verdict == models.VerdictJudged {
 		return c.JSON(http.StatusOK, map[string]interface{}{
 			"verdict": ctx.Submission.Verdict,
 		})
@@ -9,3 +9,17 @@ func Verbose() Opt {
 		s.verbose = true
 	}
 }
+
+// RemoteWorkers lets remote judge workers presenting `token` take jobs from the server.
+func RemoteWorkers(token string) Opt {
+	return func(s *Server) {
+		s.workerToken = token
+	}
+}
+
+// KeptOutput sets the amount of the submissions' output kept on each test by remote workers, in KBs.
+func KeptOutput(kbs int) Opt {
+	return func(s *Server) {
+		s.keptOutput = kbs
+	}
+}
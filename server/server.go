@@ -21,6 +21,9 @@ import (
 	"github.com/natsukagami/kjudge/server/contests"
 	"github.com/natsukagami/kjudge/server/template"
 	"github.com/natsukagami/kjudge/server/user"
+	"github.com/natsukagami/kjudge/server/workers"
+	"github.com/natsukagami/kjudge/worker"
+	"github.com/natsukagami/kjudge/worker/remote"
 	"github.com/pkg/errors"
 )
 
@@ -29,14 +32,19 @@ type Server struct {
 	db   *db.DB
 	echo *echo.Echo
 
-	verbose bool
+	verbose     bool
+	workerToken string
+	keptOutput  int
+	workers     *remote.Registry
 }
 
 // New creates a new server.
 func New(db *db.DB, opts ...Opt) (*Server, error) {
 	s := &Server{
-		db:   db,
-		echo: echo.New(),
+		db:         db,
+		echo:       echo.New(),
+		keptOutput: worker.DefaultKeptOutput,
+		workers:    remote.NewRegistry(),
 	}
 
 	for _, opt := range opts {
@@ -74,9 +82,14 @@ func New(db *db.DB, opts ...Opt) (*Server, error) {
 	if err != nil {
 		return nil, err
 	}
-	if _, err := admin.New(s.db, au, s.echo.Group("/admin")); err != nil {
+	if _, err := admin.New(s.db, au, s.workers, s.echo.Group("/admin")); err != nil {
 		return nil, err
 	}
+	if s.workerToken != "" {
+		if _, err := workers.New(s.db, s.workerToken, s.keptOutput, s.workers, s.echo.Group("/worker")); err != nil {
+			return nil, err
+		}
+	}
 	if _, err := user.New(s.db, s.echo.Group("/user")); err != nil {
 		return nil, err
 	}
@@ -11,6 +11,7 @@ import (
 
 	"github.com/labstack/echo/v4"
 	"github.com/natsukagami/kjudge/embed"
+	"github.com/natsukagami/kjudge/models"
 	"github.com/pkg/errors"
 )
 
@@ -24,8 +25,12 @@ var templateList = map[string][]string{
 	"admin/contest":               {"admin/root", "admin/contest_inputs", "admin/problem_inputs"},
 	"admin/contest_submissions":   {"admin/root", "admin/submission_inputs"},
 	"admin/contest_announcements": {"admin/root"},
-	"admin/problem":               {"admin/root", "admin/problem_inputs", "admin/test_inputs", "admin/test_group_inputs", "admin/file_inputs"},
-	"admin/test_group":            {"admin/root", "admin/test_inputs", "admin/test_group_inputs"},
+	"admin/contest_preflight":     {"admin/root", "admin/contest_inputs"},
+	"admin/problem":               {"admin/root", "admin/problem_inputs", "admin/test_inputs", "admin/test_group_inputs", "admin/file_inputs", "admin/language_limit_inputs", "admin/model_solution_inputs", "admin/admin_task_inputs"},
+	"admin/test_group":            {"admin/root", "admin/test_inputs", "admin/test_group_inputs", "admin/admin_task_inputs"},
+	"admin/generate_report":       {"admin/root", "admin/admin_task_inputs"},
+	"admin/validate_report":       {"admin/root", "admin/admin_task_inputs"},
+	"admin/invocation":            {"admin/root", "admin/model_solution_inputs", "admin/admin_task_inputs"},
 	"admin/problem_submissions":   {"admin/root", "admin/submission_inputs"},
 	"admin/users":                 {"admin/root", "admin/user_inputs"},
 	"admin/user":                  {"admin/root", "admin/user_inputs", "admin/submission_inputs"},
@@ -113,6 +118,8 @@ func parseRootTemplate() (*template.Template, error) {
 		"loggedIn": loggedIn,
 		"json":     func(item interface{}) (string, error) { b, err := json.Marshal(item); return string(b), err },
 		"zip":      func(items ...interface{}) []interface{} { return items },
+		// All defined languages, see models.LanguageSpec.
+		"languages": models.Languages,
 	})
 	tRoot, err = tRoot.Parse(string(root))
 	if err != nil {
@@ -0,0 +1,234 @@
+// Package workers implements the /worker endpoints that remote judge workers talk to.
+package workers
+
+import (
+	"crypto/subtle"
+	"database/sql"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/natsukagami/kjudge/db"
+	"github.com/natsukagami/kjudge/models"
+	"github.com/natsukagami/kjudge/server/httperr"
+	"github.com/natsukagami/kjudge/worker/remote"
+	"github.com/pkg/errors"
+)
+
+// Group is the /worker handling group.
+type Group struct {
+	group *echo.Group
+	db    *db.DB
+
+	token      string
+	registry   *remote.Registry
+	dispatcher *remote.Dispatcher
+}
+
+// New creates a new Group. Workers must present `token` to be let in, and keep `keptOutput` KBs of the
+// submissions' output on each test.
+func New(db *db.DB, token string, keptOutput int, registry *remote.Registry, g *echo.Group) (*Group, error) {
+	if token == "" {
+		return nil, errors.New("remote workers need a token")
+	}
+	grp := &Group{
+		group:      g,
+		db:         db,
+		token:      token,
+		registry:   registry,
+		dispatcher: &remote.Dispatcher{DB: db, KeptOutput: keptOutput},
+	}
+
+	g.Use(grp.mustAuth)
+	g.POST("/claim", grp.ClaimPost)
+	g.GET("/submissions/:id/compiled", grp.CompiledSourceGet)
+	g.GET("/tests/:id/input", grp.TestInputGet)
+	g.GET("/tests/:id/output", grp.TestOutputGet)
+	g.POST("/jobs/:id/heartbeat", grp.HeartbeatPost)
+	g.POST("/jobs/:id/compile", grp.CompilePost)
+	g.POST("/jobs/:id/run", grp.RunPost)
+	g.POST("/jobs/:id/fail", grp.FailPost)
+
+	return grp, nil
+}
+
+// Checks the worker's token, and marks the worker as alive.
+func (g *Group) mustAuth(h echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		token := strings.TrimPrefix(c.Request().Header.Get(remote.TokenHeader), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(g.token)) != 1 {
+			return httperr.Unauthorizedf("Invalid worker token")
+		}
+		if workerName(c) == "" {
+			return httperr.BadRequestf("Missing worker name")
+		}
+		g.seen(c, nil)
+		return h(c)
+	}
+}
+
+// Marks the worker as alive, updating its status with `update`.
+func (g *Group) seen(c echo.Context, update func(s *remote.Status)) {
+	g.registry.Seen(workerName(c), c.RealIP(), c.Request().Header.Get(remote.SandboxHeader), update)
+}
+
+// The worker's name.
+func workerName(c echo.Context) string {
+	return c.Request().Header.Get(remote.NameHeader)
+}
+
+// The owner of the jobs claimed by the worker. Kept apart from the local workers' names.
+func owner(c echo.Context) string {
+	return "remote:" + workerName(c)
+}
+
+func paramID(c echo.Context) (int, error) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return 0, httperr.NotFoundf("Not found: %s", c.Param("id"))
+	}
+	return id, nil
+}
+
+// Maps a lost job into 409 Conflict.
+func jobError(err error) error {
+	if errors.Is(err, remote.ErrJobLost) {
+		return httperr.Newf(http.StatusConflict, "%v", err)
+	}
+	return err
+}
+
+// ClaimPost implements POST /worker/claim.
+func (g *Group) ClaimPost(c echo.Context) error {
+	task, err := g.dispatcher.Claim(owner(c))
+	if err != nil {
+		return err
+	}
+	if task == nil {
+		g.seen(c, func(s *remote.Status) { s.JobID = 0 })
+		return c.NoContent(http.StatusNoContent)
+	}
+	g.seen(c, func(s *remote.Status) { s.JobID = task.JobID })
+	return c.JSON(http.StatusOK, task)
+}
+
+// CompiledSourceGet implements GET /worker/submissions/:id/compiled.
+func (g *Group) CompiledSourceGet(c echo.Context) error {
+	id, err := paramID(c)
+	if err != nil {
+		return err
+	}
+	sub, err := models.GetSubmission(g.db, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return httperr.NotFoundf("Submission not found: %d", id)
+	} else if err != nil {
+		return err
+	}
+	return c.Blob(http.StatusOK, "application/octet-stream", sub.CompiledSource)
+}
+
+func (g *Group) testGet(c echo.Context, output bool) error {
+	id, err := paramID(c)
+	if err != nil {
+		return err
+	}
+	test, err := models.GetTest(g.db, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return httperr.NotFoundf("Test not found: %d", id)
+	} else if err != nil {
+		return err
+	}
+	if output {
+		return c.Blob(http.StatusOK, "application/octet-stream", test.Output)
+	}
+	return c.Blob(http.StatusOK, "application/octet-stream", test.Input)
+}
+
+// TestInputGet implements GET /worker/tests/:id/input.
+func (g *Group) TestInputGet(c echo.Context) error {
+	return g.testGet(c, false)
+}
+
+// TestOutputGet implements GET /worker/tests/:id/output.
+func (g *Group) TestOutputGet(c echo.Context) error {
+	return g.testGet(c, true)
+}
+
+// HeartbeatPost implements POST /worker/jobs/:id/heartbeat.
+func (g *Group) HeartbeatPost(c echo.Context) error {
+	id, err := paramID(c)
+	if err != nil {
+		return err
+	}
+	if err := g.dispatcher.Heartbeat(id, owner(c)); err != nil {
+		return jobError(err)
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// Marks the job of the worker as done.
+func (g *Group) done(c echo.Context, failure string) {
+	g.seen(c, func(s *remote.Status) {
+		s.JobID = 0
+		if failure == "" {
+			s.JobsDone++
+		} else {
+			s.JobsFailed++
+			s.LastError = failure
+		}
+	})
+}
+
+// CompilePost implements POST /worker/jobs/:id/compile.
+func (g *Group) CompilePost(c echo.Context) error {
+	id, err := paramID(c)
+	if err != nil {
+		return err
+	}
+	var result remote.CompileResult
+	if err := c.Bind(&result); err != nil {
+		return httperr.BindFail(err)
+	}
+	if err := g.dispatcher.FinishCompile(id, owner(c), &result); err != nil {
+		return jobError(err)
+	}
+	g.done(c, "")
+	return c.NoContent(http.StatusNoContent)
+}
+
+// RunPost implements POST /worker/jobs/:id/run.
+func (g *Group) RunPost(c echo.Context) error {
+	id, err := paramID(c)
+	if err != nil {
+		return err
+	}
+	var result models.TestResult
+	if err := c.Bind(&result); err != nil {
+		return httperr.BindFail(err)
+	}
+	if err := g.dispatcher.FinishRun(id, owner(c), &result); err != nil {
+		return jobError(err)
+	}
+	g.done(c, "")
+	return c.NoContent(http.StatusNoContent)
+}
+
+// FailPost implements POST /worker/jobs/:id/fail.
+func (g *Group) FailPost(c echo.Context) error {
+	id, err := paramID(c)
+	if err != nil {
+		return err
+	}
+	var req remote.FailRequest
+	if err := c.Bind(&req); err != nil {
+		return httperr.BindFail(err)
+	}
+	log.Printf("[REMOTE] Worker %s failed job %d: %s\n", workerName(c), id, req.Error)
+	if err := g.dispatcher.Fail(id, owner(c), req.Error); err != nil {
+		return jobError(err)
+	}
+	g.done(c, req.Error)
+	return c.NoContent(http.StatusNoContent)
+}
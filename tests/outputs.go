@@ -0,0 +1,163 @@
+package tests
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// The outputs of output-only submissions are kept in zip archives, with each output named after its test.
+
+// Limits on the uploaded archives of outputs, so that an archive unpacking into much more than it weighs
+// (a "zip bomb") is rejected instead of filling up the memory.
+const (
+	// MaxOutputFiles is the maximum number of files in an archive of outputs.
+	MaxOutputFiles = 1000
+	// MaxOutputsSize is the maximum total size of the unpacked outputs, in bytes.
+	MaxOutputsSize = 1 << 30 // 1GB
+)
+
+// UnpackOutputs reads the outputs uploaded for an output-only problem, keyed by the names of their tests.
+// The upload is either a zip archive or a single output, named `filename`. The names of the tests are extracted
+// from the file names (without their directories) with the pattern. Files not matching the pattern are ignored.
+// Archives with more than MaxOutputFiles files, an output larger than `maxSize` bytes or outputs larger than
+// MaxOutputsSize bytes in total are rejected.
+func UnpackOutputs(content []byte, filename, pattern string, maxSize int64) (map[string][]byte, error) {
+	p, err := ParsePattern(pattern)
+	if err != nil {
+		return nil, errors.Wrap(err, "output pattern")
+	}
+	outputs := make(map[string][]byte)
+	if !strings.EqualFold(path.Ext(filename), ".zip") {
+		if int64(len(content)) > maxSize {
+			return nil, errors.Errorf("the output is larger than %d bytes", maxSize)
+		}
+		if name, ok := p.Match(path.Base(filename)); ok {
+			outputs[name] = content
+		}
+		return outputs, nil
+	}
+	archive, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if len(archive.File) > MaxOutputFiles {
+		return nil, errors.Errorf("the archive has %d files, more than %d", len(archive.File), MaxOutputFiles)
+	}
+	remaining := int64(MaxOutputsSize)
+	for _, f := range archive.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		name, ok := p.Match(path.Base(f.Name))
+		if !ok {
+			continue
+		}
+		if _, ok := outputs[name]; ok {
+			return nil, errors.Errorf("duplicate output for test %s", name)
+		}
+		limit := maxSize
+		if limit > remaining {
+			limit = remaining
+		}
+		if outputs[name], err = readLimitedZipFile(f, limit); err != nil {
+			return nil, errors.Wrapf(err, "file %s", f.Name)
+		}
+		remaining -= int64(len(outputs[name]))
+	}
+	return outputs, nil
+}
+
+// PackOutputs packs the outputs, keyed by the names of their tests, into a zip archive.
+func PackOutputs(outputs map[string][]byte) ([]byte, error) {
+	names := make([]string, 0, len(outputs))
+	for name := range outputs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for _, name := range names {
+		f, err := w.Create(name)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		if _, err := f.Write(outputs[name]); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return buf.Bytes(), nil
+}
+
+// ReadOutputs reads all outputs of an archive packed by PackOutputs.
+func ReadOutputs(archive []byte) (map[string][]byte, error) {
+	r, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	outputs := make(map[string][]byte)
+	for _, f := range r.File {
+		if outputs[f.Name], err = readZipFile(f); err != nil {
+			return nil, errors.Wrapf(err, "file %s", f.Name)
+		}
+	}
+	return outputs, nil
+}
+
+// ReadOutput reads the output of the test from an archive packed by PackOutputs.
+// Returns false if there is no output for the test.
+func ReadOutput(archive []byte, testName string) ([]byte, bool, error) {
+	r, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+	if err != nil {
+		return nil, false, errors.WithStack(err)
+	}
+	for _, f := range r.File {
+		if f.Name == testName {
+			content, err := readZipFile(f)
+			if err != nil {
+				return nil, false, errors.Wrapf(err, "file %s", f.Name)
+			}
+			return content, true, nil
+		}
+	}
+	return nil, false, nil
+}
+
+// Reads the file of the archive, failing if it is larger than `limit` bytes.
+// The size recorded in the archive is not trusted, the file is read until the limit at most.
+func readLimitedZipFile(f *zip.File, limit int64) ([]byte, error) {
+	if f.UncompressedSize64 > uint64(limit) {
+		return nil, errors.Errorf("the file is larger than %d bytes", limit)
+	}
+	rd, err := f.Open()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer rd.Close()
+	content, err := io.ReadAll(io.LimitReader(rd, limit+1))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if int64(len(content)) > limit {
+		return nil, errors.Errorf("the file is larger than %d bytes", limit)
+	}
+	return content, nil
+}
+
+func readZipFile(f *zip.File) ([]byte, error) {
+	rd, err := f.Open()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer rd.Close()
+	content, err := io.ReadAll(rd)
+	return content, errors.WithStack(err)
+}
@@ -11,6 +11,7 @@ import (
 )
 
 // LazyTest is a `models.Test` but input and outputs are `zip.File`s.
+// Output is nil if only the input was uploaded (see UnpackInputs).
 type LazyTest struct {
 	models.Test
 	Input, Output *zip.File
@@ -56,6 +57,32 @@ func Unpack(zipFile io.ReaderAt, size int64, input, output string) ([]*LazyTest,
 	return matchTests(inputs, outputs), nil
 }
 
+// UnpackInputs works like Unpack, but only extracts the inputs of the tests, leaving their outputs nil
+// (to be generated by the problem's reference solution).
+func UnpackInputs(zipFile io.ReaderAt, size int64, input string) ([]*LazyTest, error) {
+	file, err := zip.NewReader(zipFile, size)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	inP, err := ParsePattern(input)
+	if err != nil {
+		return nil, errors.Wrap(err, "input pattern")
+	}
+	inputs := make(textMap)
+	for _, f := range file.File {
+		if name, ok := inP.Match(f.Name); ok {
+			if err := readToMap(f, name, inputs); err != nil {
+				return nil, errors.Wrapf(err, "file %s", f.Name)
+			}
+		}
+	}
+	var res []*LazyTest
+	for name, input := range inputs {
+		res = append(res, &LazyTest{Test: models.Test{Name: name}, Input: input})
+	}
+	return res, nil
+}
+
 func matchTests(in, out textMap) []*LazyTest {
 	var res []*LazyTest
 	for name, input := range in {
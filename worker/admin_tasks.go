@@ -0,0 +1,58 @@
+package worker
+
+import (
+	"log"
+
+	"github.com/natsukagami/kjudge/models"
+	"github.com/pkg/errors"
+)
+
+// HandleAdminTask runs the admins' task, and records its report (or why it could not be done).
+// Tasks are only run when no judging jobs are ready, and are not retried.
+func (q *Queue) HandleAdminTask(task *models.AdminTask) error {
+	report, err := q.runAdminTask(task)
+	if err != nil {
+		log.Printf("[WORKER] Admin task %d (%s) failed: %v\n", task.ID, task.Type, err)
+	}
+	return task.Finish(q.DB, report, err)
+}
+
+func (q *Queue) runAdminTask(task *models.AdminTask) (interface{}, error) {
+	problem, err := models.GetProblem(q.DB, task.ProblemID)
+	if err != nil {
+		return nil, err
+	}
+	testGroups, err := models.GetProblemTests(q.DB, problem.ID)
+	if err != nil {
+		return nil, err
+	}
+	if task.TestGroupID.Valid {
+		var limited []*models.TestGroupWithTests
+		for _, tg := range testGroups {
+			if int64(tg.ID) == task.TestGroupID.Int64 {
+				limited = append(limited, tg)
+			}
+		}
+		testGroups = limited
+	}
+	switch task.Type {
+	case models.AdminTaskTypeGenerate:
+		return GenerateOutputs(q.Sandbox, &GenerateContext{DB: q.DB, Problem: problem, TestGroups: testGroups})
+	case models.AdminTaskTypeValidate:
+		validator, err := GetValidator(q.DB, problem.ID)
+		if err != nil {
+			return nil, err
+		}
+		if validator == nil {
+			return nil, errors.Errorf("the problem has no `%s` file", ValidatorFilename)
+		}
+		return ValidateTests(q.Sandbox, q.DB, validator, testGroups)
+	case models.AdminTaskTypeInvoke:
+		solutions, err := models.GetProblemModelSolutions(q.DB, problem.ID)
+		if err != nil {
+			return nil, err
+		}
+		return Invoke(q.Sandbox, &InvocationContext{DB: q.DB, Problem: problem, Solutions: solutions, TestGroups: testGroups})
+	}
+	return nil, errors.Errorf("unknown task type %s", task.Type)
+}
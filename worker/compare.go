@@ -0,0 +1,146 @@
+package worker
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/natsukagami/kjudge/models"
+)
+
+// The built-in comparators, used by the problems' comparison modes (see models.ComparisonMode).
+// They run inside the worker, without the sandbox.
+
+// The length of the output shown in the comparators' messages, in bytes.
+const compareSnippetLength = 32
+
+// The numbers compared by the numeric comparison mode: plain decimals, with an optional exponent.
+// strconv.ParseFloat also takes hexadecimal floats, "inf" and "nan", which must not match the expected numbers.
+var numberPattern = regexp.MustCompile(`^[+-]?([0-9]+\.?[0-9]*|\.[0-9]+)([eE][+-]?[0-9]+)?$`)
+
+// compareBuiltin compares the outputs with the problem's comparison mode, and reflects the comparison into `result`.
+func compareBuiltin(p *models.Problem, output, expected []byte, result *models.TestResult) {
+	var message string
+	switch p.ComparisonMode {
+	case models.ComparisonModeExact:
+		message = compareExact(output, expected)
+	case models.ComparisonModeLines:
+		message = compareLines(string(output), string(expected))
+	case models.ComparisonModeTokensCaseInsensitive:
+		message = compareTokens(output, expected, strings.EqualFold)
+	case models.ComparisonModeNumeric:
+		message = compareTokens(output, expected, func(got, want string) bool {
+			return numbersEqual(got, want, p.ComparisonAbsEpsilon, p.ComparisonRelEpsilon)
+		})
+	default:
+		message = compareTokens(output, expected, func(got, want string) bool { return got == want })
+	}
+	if message == "" {
+		result.Score = 1
+		result.Verdict = "Accepted"
+		return
+	}
+	result.Score = 0
+	result.Verdict = message
+}
+
+// Shortens a part of the output to be shown in the messages, without cutting a UTF-8 character in half.
+func snippet(s string) string {
+	if len(s) <= compareSnippetLength {
+		return s
+	}
+	end := compareSnippetLength
+	for end > 0 && !utf8.RuneStart(s[end]) {
+		end--
+	}
+	return s[:end] + "..."
+}
+
+func compareExact(output, expected []byte) string {
+	if bytes.Equal(output, expected) {
+		return ""
+	}
+	i := 0
+	for i < len(output) && i < len(expected) && output[i] == expected[i] {
+		i++
+	}
+	// The snippets start at the character the outputs differ in, which might begin before the differing byte.
+	start := i
+	for start > 0 && (start < len(output) && !utf8.RuneStart(output[start]) || start < len(expected) && !utf8.RuneStart(expected[start])) {
+		start--
+	}
+	switch {
+	case i == len(output):
+		return fmt.Sprintf("byte %d: expected %q, got end of output", i+1, snippet(string(expected[start:])))
+	case i == len(expected):
+		return fmt.Sprintf("byte %d: expected end of output, got %q", i+1, snippet(string(output[start:])))
+	default:
+		return fmt.Sprintf("byte %d: expected %q, got %q", i+1, snippet(string(expected[start:])), snippet(string(output[start:])))
+	}
+}
+
+// Compares the whitespace-separated tokens of the outputs with `equal`, which takes the submission's token first.
+func compareTokens(output, expected []byte, equal func(got, want string) bool) string {
+	got, want := strings.Fields(string(output)), strings.Fields(string(expected))
+	for i := 0; i < len(got) || i < len(want); i++ {
+		switch {
+		case i == len(got):
+			return fmt.Sprintf("token %d: expected %s, got end of output", i+1, snippet(want[i]))
+		case i == len(want):
+			return fmt.Sprintf("token %d: expected end of output, got %s", i+1, snippet(got[i]))
+		case !equal(got[i], want[i]):
+			return fmt.Sprintf("token %d: expected %s, got %s", i+1, snippet(want[i]), snippet(got[i]))
+		}
+	}
+	return ""
+}
+
+// Splits the output into lines, without the trailing whitespace and the trailing empty lines.
+func outputLines(s string) []string {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t\r\f\v")
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+func compareLines(output, expected string) string {
+	got, want := outputLines(output), outputLines(expected)
+	for i := 0; i < len(got) || i < len(want); i++ {
+		switch {
+		case i == len(got):
+			return fmt.Sprintf("line %d: expected %q, got end of output", i+1, snippet(want[i]))
+		case i == len(want):
+			return fmt.Sprintf("line %d: expected end of output, got %q", i+1, snippet(got[i]))
+		case got[i] != want[i]:
+			return fmt.Sprintf("line %d: expected %q, got %q", i+1, snippet(want[i]), snippet(got[i]))
+		}
+	}
+	return ""
+}
+
+// Compares two tokens as numbers, within the absolute or relative epsilon.
+// Tokens that are not both numbers must be equal.
+func numbersEqual(got, want string, absEpsilon, relEpsilon float64) bool {
+	if got == want {
+		return true
+	}
+	if !numberPattern.MatchString(got) || !numberPattern.MatchString(want) {
+		return false
+	}
+	a, errA := strconv.ParseFloat(got, 64)
+	b, errB := strconv.ParseFloat(want, 64)
+	// Numbers out of range are parsed into infinities.
+	if errA != nil || errB != nil || math.IsInf(a, 0) || math.IsInf(b, 0) {
+		return false
+	}
+	diff := math.Abs(a - b)
+	return diff <= absEpsilon || diff <= relEpsilon*math.Abs(b)
+}
@@ -0,0 +1,119 @@
+package worker
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/natsukagami/kjudge/models"
+)
+
+func TestNumbersEqual(t *testing.T) {
+	tests := []struct {
+		got, want string
+		abs, rel  float64
+		equal     bool
+	}{
+		{"3.1416", "3.1416", 0, 0, true},
+		{"3.1416", "3.14159", 1e-4, 0, true},
+		{"3.15", "3.1416", 1e-4, 0, false},
+		{"1000.5", "1000", 0, 1e-3, true},
+		{"1001.5", "1000", 0, 1e-3, false},
+		{"-0", "0", 0, 0, true},
+		{"+2", "2", 0, 0, true},
+		{".5", "0.5", 0, 0, true},
+		{"5.", "5", 0, 0, true},
+		{"1e3", "1000", 0, 0, true},
+		{"1E-3", "0.001", 1e-9, 0, true},
+		{"abc", "abc", 0, 0, true},
+		{"abc", "abd", 1, 1, false},
+		{"0x1p-2", "0.25", 1, 1, false},
+		{"inf", "1e308", 1, 1, false},
+		{"Infinity", "1", 1e308, 1, false},
+		{"nan", "nan2", 1, 1, false},
+		{"NaN", "0", 1e308, 1, false},
+		{"1_000", "1000", 1, 1, false},
+		{"1e400", "1e308", 1e308, 1, false},
+		{"", "0", 1, 1, false},
+		{"-", "0", 1, 1, false},
+		{"1e", "1", 1, 1, false},
+	}
+	for _, test := range tests {
+		if got := numbersEqual(test.got, test.want, test.abs, test.rel); got != test.equal {
+			t.Errorf("numbersEqual(%q, %q, %v, %v) = %v, expected %v", test.got, test.want, test.abs, test.rel, got, test.equal)
+		}
+	}
+}
+
+func TestCompareBuiltin(t *testing.T) {
+	tests := []struct {
+		name     string
+		mode     models.ComparisonMode
+		output   string
+		expected string
+		message  string // "" if accepted
+	}{
+		{"exact equal", models.ComparisonModeExact, "1 2\n", "1 2\n", ""},
+		{"exact whitespace", models.ComparisonModeExact, "1 2", "1 2\n", `byte 4: expected "\n", got end of output`},
+		{"exact longer", models.ComparisonModeExact, "1 2\n\n", "1 2\n", `byte 5: expected end of output, got "\n"`},
+		{"exact differs", models.ComparisonModeExact, "1 3\n", "1 2\n", `byte 3: expected "2\n", got "3\n"`},
+		{"exact multibyte", models.ComparisonModeExact, "xé", "xè", `byte 3: expected "è", got "é"`},
+		{"tokens whitespace", models.ComparisonModeTokens, " 1\t2 \n\n", "1 2\n", ""},
+		{"tokens differ", models.ComparisonModeTokens, "1 3", "1 2", "token 2: expected 2, got 3"},
+		{"tokens missing", models.ComparisonModeTokens, "1", "1 2", "token 2: expected 2, got end of output"},
+		{"tokens extra", models.ComparisonModeTokens, "1 2 3", "1 2", "token 3: expected end of output, got 3"},
+		{"tokens case", models.ComparisonModeTokens, "yes", "YES", "token 1: expected YES, got yes"},
+		{"tokens case-insensitive", models.ComparisonModeTokensCaseInsensitive, "yes No", "YES no", ""},
+		{"lines trailing whitespace", models.ComparisonModeLines, "a b  \r\nc\n\n\n", "a b\nc", ""},
+		{"lines inner whitespace", models.ComparisonModeLines, "a  b\nc", "a b\nc", `line 1: expected "a b", got "a  b"`},
+		{"lines missing", models.ComparisonModeLines, "a", "a\nb", `line 2: expected "b", got end of output`},
+		{"numeric within", models.ComparisonModeNumeric, "3.14159 2", "3.1416 2", ""},
+		{"numeric outside", models.ComparisonModeNumeric, "3.15", "3.1416", "token 1: expected 3.1416, got 3.15"},
+		{"numeric hex", models.ComparisonModeNumeric, "0x1.921fbp+1", "3.1416", "token 1: expected 3.1416, got 0x1.921fbp+1"},
+		{"numeric nan", models.ComparisonModeNumeric, "nan", "3.1416", "token 1: expected 3.1416, got nan"},
+		{"numeric words", models.ComparisonModeNumeric, "YES 1", "YES 1.0000001", ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := &models.Problem{ComparisonMode: test.mode, ComparisonAbsEpsilon: 1e-4}
+			var result models.TestResult
+			compareBuiltin(p, []byte(test.output), []byte(test.expected), &result)
+			if test.message == "" {
+				if result.Score != 1 || result.Verdict != "Accepted" {
+					t.Errorf("expected Accepted, got score %v: %s", result.Score, result.Verdict)
+				}
+				return
+			}
+			if result.Score != 0 || result.Verdict != test.message {
+				t.Errorf("expected %q, got score %v: %q", test.message, result.Score, result.Verdict)
+			}
+		})
+	}
+}
+
+func TestSnippet(t *testing.T) {
+	tests := []string{
+		"short",
+		strings.Repeat("a", compareSnippetLength),
+		strings.Repeat("a", compareSnippetLength+1),
+		strings.Repeat("é", compareSnippetLength),
+		"a" + strings.Repeat("é", compareSnippetLength),
+		strings.Repeat("😀", compareSnippetLength),
+	}
+	for _, s := range tests {
+		got := snippet(s)
+		if !utf8.ValidString(got) {
+			t.Errorf("snippet(%q) = %q is not valid UTF-8", s, got)
+		}
+		if len(s) <= compareSnippetLength {
+			if got != s {
+				t.Errorf("snippet(%q) = %q, expected it unchanged", s, got)
+			}
+			continue
+		}
+		cut := strings.TrimSuffix(got, "...")
+		if cut == got || !strings.HasPrefix(s, cut) || len(cut) > compareSnippetLength || len(cut) <= compareSnippetLength-utf8.UTFMax {
+			t.Errorf("snippet(%q) = %q, expected a prefix of at most %d bytes and ...", s, got, compareSnippetLength)
+		}
+	}
+}
@@ -1,12 +1,28 @@
 package worker
 
+// How each language is compiled is defined by the language definitions (see models.LanguageSpec and
+// embed/assets/languages.toml).
+//
 // Compiling anything that's more compicated than single file:
 //
-// - Prepare a "compile_%s.%ext" file, with %s being the language (cc, go, rs, java, py2, py3, pas)
+// - Prepare a batch file named after the language's "batch" entry (by default "compile_%s.sh", with %s being one of
+//   cc, go, rs, java, py2, py3, pas)
 // - Prepare any more files as needed. They will all be put into the CWD of the script
-// - The CWD also contains "code.%s" (%s being the language's respective extension) file, which is the contestant's source code.
-// - The script should do whatever it wants (unsandboxed, because it's not my job to do so) within 20 seconds.
+// - The CWD also contains the language's "source" file (by default "code.%s", %s being the language's respective
+//   extension), which is the contestant's source code.
+// - The script should do whatever it wants within the compile limits (see below).
 // - It should produce a single binary called "code" in the CWD.
+//
+// Compilers and scripts run inside the sandbox, with their own limits on time, memory, processes and the size of
+// the files written. Each command of a compilation runs in a fresh box, given the files left by the previous one.
+//
+// Problems with graders ("grader.%ext" files, see models.Graders) compile the grader of the submission's language
+// together with the contestant's source code, with the language's "grader_compile" commands. With the built-in
+// definitions, the grader holds the entry point:
+// - C++, Go, Java: the grader is simply compiled along with "code.%ext", sharing any header files given.
+// - Pascal: the grader is the program, and the contestant's source code is the unit "code".
+// - Rust: the grader is the crate root, and the contestant's source code is the module "code".
+// - Python: the grader is the "__main__.py" of a zip application, which can "import code".
 
 import (
 	"bytes"
@@ -18,27 +34,50 @@ import (
 	"strings"
 	"time"
 
-	"github.com/jmoiron/sqlx"
 	"github.com/natsukagami/kjudge/models"
+	"github.com/natsukagami/kjudge/worker/sandbox"
 	"github.com/pkg/errors"
 )
 
-// CompileContext is the information needed to perform compilation.
-type CompileContext struct {
-	DB      *sqlx.Tx
-	Sub     *models.Submission
-	Problem *models.Problem
+// The limits applied to every command of a compilation.
+const (
+	CompileTimeLimit    = 20 * time.Second
+	CompileMemoryLimit  = 1 << 20 // 1 GB, in KBs
+	CompileMaxProcesses = 128
+	CompileOutputLimit  = 65536 // 64 MBs per file, in KBs
+)
+
+// The environment given to compilers.
+var compileEnv = []string{"PATH=/usr/local/bin:/usr/bin:/bin", "HOME=/tmp", "GOCACHE=/tmp/go-cache"}
+
+func init() {
+	// Toolchains installed elsewhere are found through these variables.
+	for _, name := range []string{"RUSTUP_HOME", "CARGO_HOME", "RUSTUP_TOOLCHAIN", "GOROOT", "JAVA_HOME"} {
+		if value, ok := os.LookupEnv(name); ok {
+			compileEnv = append(compileEnv, name+"="+value)
+		}
+	}
+	// rustup keeps its toolchains in the user's home by default, which compilers do not see.
+	if _, ok := os.LookupEnv("RUSTUP_HOME"); !ok {
+		if home, err := os.UserHomeDir(); err == nil {
+			if _, err := os.Stat(filepath.Join(home, ".rustup")); err == nil {
+				compileEnv = append(compileEnv, "RUSTUP_HOME="+filepath.Join(home, ".rustup"))
+			}
+		}
+	}
 }
 
-// Compile performs compilation.
+// CompileSubmission compiles the submission with the given problem files, putting the results into the submission.
+// It does not touch the database, so that remote workers can also do it.
 // Returns whether the compilation succeeds.
-func Compile(c *CompileContext) (bool, error) {
-	// First we gotta know which compilation scheme we will be taking.
-	files, err := models.GetProblemFiles(c.DB, c.Problem.ID)
-	if err != nil {
-		return false, err
+func CompileSubmission(s sandbox.Runner, sub *models.Submission, files []*models.File) (bool, error) {
+	if sub.OutputOnly() {
+		// The archive of outputs is run as-is. The compiler output keeps the summary of the upload.
+		sub.CompiledSource = sub.Source
+		return true, nil
 	}
-	action, batchFile, err := CompileBatch(c.Sub.Language)
+	// First we gotta know which compilation scheme we will be taking.
+	action, batchFile, err := CompileBatch(sub.Language)
 	if err != nil {
 		return false, err
 	}
@@ -46,59 +85,62 @@ func Compile(c *CompileContext) (bool, error) {
 	hasBatch := false
 	for _, file := range files {
 		hasBatch = hasBatch || isBatchFile(file.Filename)
-		if file.Filename == batchFile {
+		if batchFile != "" && file.Filename == batchFile {
 			hasFile = true
 			break
 		}
 	}
-	if !hasBatch {
+	if graders := models.Graders(files); !hasBatch && len(graders) > 0 {
+		// Link the submission with the grader.
+		grader, ok := graders[sub.Language]
+		if !ok || !sub.Language.Spec().SupportsGraders() {
+			sub.CompiledSource = nil
+			sub.Verdict = models.VerdictCompileError
+			sub.CompilerOutput = []byte("This problem does not have a grader for this language.")
+			return false, nil
+		}
+		action, err = CompileWithGrader(sub.Language, grader.Filename)
+		if err != nil {
+			return false, err
+		}
+	} else if !hasBatch {
 		// No batch file, compiling as a single file.
-		action, err = CompileSingle(c.Sub.Language)
+		action, err = CompileSingle(sub.Language)
 		if err != nil {
 			return false, err
 		}
 	} else if !hasFile {
 		// Batch compile mode enabled, but this language is not supported.
-		c.Sub.CompiledSource = nil
-		c.Sub.Verdict = models.VerdictCompileError
-		c.Sub.CompilerOutput = []byte("Custom Compilers are not enabled for this language.")
-		return false, c.Sub.Write(c.DB)
+		sub.CompiledSource = nil
+		sub.Verdict = models.VerdictCompileError
+		sub.CompilerOutput = []byte("Custom Compilers are not enabled for this language.")
+		return false, nil
 	}
 
-	log.Printf("[WORKER] Compiling submission %v\n", c.Sub.ID)
-
-	// Now, create a temporary directory.
-	dir, err := os.MkdirTemp("", "*")
-	if err != nil {
-		return false, errors.WithStack(err)
-	}
-	defer action.Cleanup(dir)
+	log.Printf("[WORKER] Compiling submission %v\n", sub.ID)
 
 	// Prepare source and files
-	action.Source.Content = c.Sub.Source
+	action.Source.Content = sub.Source
 	action.Files = files
-	if err := action.Prepare(dir); err != nil {
-		return false, err
-	}
 
 	// Perform compilation
-	result, messages := action.Perform(dir)
-	c.Sub.CompilerOutput = messages
+	output, messages, err := action.Run(s)
+	if err != nil {
+		return false, err
+	}
+	sub.CompilerOutput = messages
+	result := output != nil
 
 	if result {
 		// Success!
-		output, err := os.ReadFile(filepath.Join(dir, action.Output))
-		if err != nil {
-			return false, errors.WithStack(err)
-		}
-		c.Sub.CompiledSource = output
+		sub.CompiledSource = output
 	} else {
-		c.Sub.CompiledSource = nil
-		c.Sub.Verdict = models.VerdictCompileError
+		sub.CompiledSource = nil
+		sub.Verdict = models.VerdictCompileError
 	}
-	log.Printf("[WORKER] Compiling submission %v succeeded (result = %v).", c.Sub.ID, result)
+	log.Printf("[WORKER] Compiling submission %v succeeded (result = %v).", sub.ID, result)
 
-	return result, c.Sub.Write(c.DB)
+	return result, nil
 }
 
 // CompileAction represents the following steps:
@@ -132,7 +174,56 @@ func (c *CompileAction) Cleanup(dir string) {
 	_ = os.RemoveAll(dir)
 }
 
-// Perform performs the compile action on the given directory.
+// Run performs the compile action inside the sandbox, with the compile limits.
+// Returns the content of the "Output" file (nil if the compilation failed), along with the compilation messages.
+func (c *CompileAction) Run(s sandbox.Runner) (output []byte, messages []byte, err error) {
+	files := map[string][]byte{c.Source.Filename: c.Source.Content}
+	for _, file := range c.Files {
+		files[file.Filename] = file.Content
+	}
+	allOutputs := bytes.Buffer{}
+	for _, command := range c.Commands {
+		allOutputs.WriteString(fmt.Sprintf("%s:\n", strings.Join(command, " ")))
+		path, err := exec.LookPath(command[0])
+		if err != nil {
+			allOutputs.WriteString(fmt.Sprintf("Compiler not found: %v\n", err))
+			return nil, allOutputs.Bytes(), nil
+		}
+		result, err := s.Run(&sandbox.Input{
+			Command:      path,
+			Args:         command[1:],
+			Files:        files,
+			TimeLimit:    CompileTimeLimit,
+			MemoryLimit:  CompileMemoryLimit,
+			MaxProcesses: CompileMaxProcesses,
+			OutputLimit:  CompileOutputLimit,
+			Env:          compileEnv,
+			CollectFiles: true,
+		})
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "running %s", command[0])
+		}
+		allOutputs.Write(result.Stdout)
+		allOutputs.Write(result.Stderr)
+		allOutputs.WriteString("\n")
+		if !result.Success {
+			if result.ErrorMessage != "" {
+				allOutputs.WriteString(result.ErrorMessage + "\n")
+			}
+			return nil, allOutputs.Bytes(), nil
+		}
+		files = result.Files
+	}
+	output, ok := files[c.Output]
+	if !ok {
+		allOutputs.WriteString(fmt.Sprintf("The compiler did not produce \"%s\"\n", c.Output))
+		return nil, allOutputs.Bytes(), nil
+	}
+	return output, allOutputs.Bytes(), nil
+}
+
+// Perform performs the compile action on the given directory, on the host, without a sandbox.
+// This is only meant for trusted sources, such as the problem files given by the admin.
 // The directory MUST contain all files given by the Problem, PLUS the written "Source" file.
 func (c *CompileAction) Perform(cwd string) (succeeded bool, messages []byte) {
 	allOutputs := bytes.Buffer{}
@@ -167,113 +258,73 @@ func (c *CompileAction) Perform(cwd string) (succeeded bool, messages []byte) {
 	return true, allOutputs.Bytes()
 }
 
-var batchFilenames = []string{
-	"compile_cc.sh", "compile_go.sh", "compile_java.sh", "compile_rs.sh", "compile_pas.sh", "compile_py2.sh", "compile_py3.sh",
-}
-
+// Returns whether the file is the custom compile script of any language.
 func isBatchFile(filename string) bool {
-	for _, f := range batchFilenames {
-		if f == filename {
+	for _, l := range models.Languages() {
+		if l.Batch != "" && l.Batch == filename {
 			return true
 		}
 	}
 	return false
 }
 
+// Returns the definition of the language.
+func languageSpec(l models.Language) (*models.LanguageSpec, error) {
+	spec := l.Spec()
+	if spec == nil {
+		return nil, errors.Errorf("Unknown language: %v", l)
+	}
+	return spec, nil
+}
+
 // CompileBatch returns a compile action, along with the required batch filename
-// in order to successfully compile.
+// in order to successfully compile. The batch filename is empty if the language does not support custom compilers.
 func CompileBatch(l models.Language) (*CompileAction, string, error) {
-	var source, batch string
-	switch l {
-	case models.LanguageCpp:
-		source = "code.cc"
-		batch = "compile_cc.sh"
-	case models.LanguageGo:
-		source = "code.go"
-		batch = "compile_go.sh"
-	case models.LanguageJava:
-		source = "code.java"
-		batch = "compile_java.sh"
-	case models.LanguageRust:
-		source = "code.rs"
-		batch = "compile_rs.sh"
-	case models.LanguagePas:
-		source = "code.pas"
-		batch = "compile_pas.sh"
-	case models.LanguagePy2:
-		source = "code.py"
-		batch = "compile_py2.sh"
-	case models.LanguagePy3:
-		source = "code.py"
-		batch = "compile_py3.sh"
-	default:
-		return nil, "", errors.New("unknown language")
+	spec, err := languageSpec(l)
+	if err != nil {
+		return nil, "", err
 	}
-
 	return &CompileAction{
-		Source:   &models.File{Filename: source},
-		Commands: [][]string{{"sh", batch}},
+		Source:   &models.File{Filename: spec.Source},
+		Commands: [][]string{{"sh", spec.Batch}},
 		Output:   "code",
-	}, batch, nil
+	}, spec.Batch, nil
 }
 
 // CompileSingle creates a compilation command for a single source code file.
 // Sometimes this is as simple as "copy".
 func CompileSingle(l models.Language) (*CompileAction, error) {
-	switch l {
-	case models.LanguageCpp:
-		return &CompileAction{
-			Source:   &models.File{Filename: "code.cc"},
-			Commands: [][]string{{"g++", "-std=c++17", "-O2", "-s", "-lm", "-DONLINE_JUDGE", "-DKJUDGE", "-o", "code", "code.cc"}},
-			Output:   "code",
-		}, nil
-	case models.LanguageGo:
-		return &CompileAction{
-			Source:   &models.File{Filename: "code.go"},
-			Commands: [][]string{{"go", "build", "-buildmode=exe", "-tags", "online_judge,kjudge", "-o", "code", "code.go"}},
-			Output:   "code",
-		}, nil
-	case models.LanguageJava:
-		return &CompileAction{
-			Source: &models.File{Filename: "code.java"},
-			Commands: [][]string{
-				{"javac", "-d", ".", "code.java"},
-				{"sh", "-c", "jar cf code *.class"},
-				{"sh", "-c", "rm *.class"},
-			},
-			Output: "code",
-		}, nil
-	case models.LanguagePas:
-		return &CompileAction{
-			Source: &models.File{Filename: "code.pas"},
-			Commands: [][]string{
-				{"fpc", "-O3", "-dONLINE_JUDGE", "-dKJUDGE", "-ocode", "code.pas"},
-			},
-			Output: "code",
-		}, nil
-	case models.LanguageRust:
-		return &CompileAction{
-			Source: &models.File{Filename: "code.rs"},
-			Commands: [][]string{
-				{"rustc", "-O", "--cfg", "online_judge", "--cfg", "kjudge", "-o", "code", "code.rs"},
-			},
-			Output: "code",
-		}, nil
-	case models.LanguagePy2:
-		return &CompileAction{
-			Source: &models.File{Filename: "code.py"},
-			Commands: [][]string{
-				{"python2", "-m", "py_compile", "code.py"},
-			},
-			Output: "code.pyc",
-		}, nil
-	case models.LanguagePy3:
-		return &CompileAction{
-			Source:   &models.File{Filename: "code.py"},
-			Commands: [][]string{{"python3", "-c", `import py_compile as m; m.compile("code.py", "code.pyc", doraise=True)`}},
-			Output:   "code.pyc",
-		}, nil
-	default:
-		return nil, errors.Errorf("Unknown language: %v", l)
+	spec, err := languageSpec(l)
+	if err != nil {
+		return nil, err
 	}
+	return &CompileAction{
+		Source:   &models.File{Filename: spec.Source},
+		Commands: spec.Compile,
+		Output:   spec.Output,
+	}, nil
+}
+
+// CompileWithGrader creates a compilation command for the source code file, linked with the given grader.
+func CompileWithGrader(l models.Language, grader string) (*CompileAction, error) {
+	spec, err := languageSpec(l)
+	if err != nil {
+		return nil, err
+	}
+	if !spec.SupportsGraders() {
+		return nil, errors.Errorf("Language %v does not support graders", l)
+	}
+	var commands [][]string
+	for _, command := range spec.GraderCompile {
+		c := make([]string, len(command))
+		for i, arg := range command {
+			c[i] = strings.ReplaceAll(arg, "{grader}", grader)
+		}
+		commands = append(commands, c)
+	}
+	return &CompileAction{
+		Source:   &models.File{Filename: spec.Source},
+		Commands: commands,
+		Output:   spec.GraderOutput,
+	}, nil
 }
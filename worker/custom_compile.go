@@ -9,6 +9,7 @@ import (
 )
 
 // CustomCompile tries to compile a file from the given source file.
+// testlib.h is given to the compiler, so that testlib checkers can be compiled as-is.
 func CustomCompile(source *models.File, files []*models.File) (*models.File, error) {
 	ext := filepath.Ext(source.Filename)
 	language, err := models.LanguageByExt(ext)
@@ -27,7 +28,9 @@ func CustomCompile(source *models.File, files []*models.File) (*models.File, err
 	}
 	defer action.Cleanup(dir)
 	action.Source.Content = source.Content
-	action.Files = files
+	if action.Files, err = withTestlib(source, files); err != nil {
+		return nil, err
+	}
 
 	if err := action.Prepare(dir); err != nil {
 		return nil, err
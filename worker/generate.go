@@ -0,0 +1,189 @@
+package worker
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/natsukagami/kjudge/db"
+	"github.com/natsukagami/kjudge/models"
+	"github.com/natsukagami/kjudge/worker/sandbox"
+	"github.com/pkg/errors"
+)
+
+// The expected outputs of tests can be generated by running the problem's reference solution (see
+// models.Problem.ReferenceSolution) on their inputs. This is done by the admins before the contest, as a task
+// run by the local workers in the background (see models.AdminTask).
+
+// The share of the time limit above which the reference solution's running time is reported,
+// as the contestants' solutions might not make it in time.
+const nearTimeLimitRatio = 0.8
+
+// GenerateContext is the context needed to generate the expected outputs of tests.
+type GenerateContext struct {
+	DB      db.DBContext
+	Problem *models.Problem
+	// The test groups whose tests get their outputs generated, with the tests' inputs.
+	TestGroups []*models.TestGroupWithTests
+}
+
+// GeneratedOutput is the result of running the reference solution on a test.
+type GeneratedOutput struct {
+	TestGroup *models.TestGroup
+	TestID    int
+	TestName  string
+
+	RunningTime int // in milliseconds
+	MemoryUsed  int // in KBs
+	TimeLimit   int // in milliseconds
+	// Why the reference solution failed on the test, in which case the test keeps its output. Empty on success.
+	Error string
+}
+
+// NearTimeLimit returns whether the reference solution came near the time limit on the test.
+func (r *GeneratedOutput) NearTimeLimit() bool {
+	return float64(r.RunningTime) >= nearTimeLimitRatio*float64(r.TimeLimit)
+}
+
+// GenerateReport is the report of generating the expected outputs of tests.
+type GenerateReport struct {
+	// The reference solution that generated the outputs.
+	ReferenceSolution string
+	// The reference solution's compiler output, if it failed to compile. No tests are run then.
+	CompileError string
+	Outputs      []*GeneratedOutput
+}
+
+// Failed returns the tests that the reference solution failed on.
+func (r *GenerateReport) Failed() []*GeneratedOutput {
+	var res []*GeneratedOutput
+	for _, o := range r.Outputs {
+		if o.Error != "" {
+			res = append(res, o)
+		}
+	}
+	return res
+}
+
+// NearTimeLimit returns the tests that the reference solution passed, but came near the time limit on.
+func (r *GenerateReport) NearTimeLimit() []*GeneratedOutput {
+	var res []*GeneratedOutput
+	for _, o := range r.Outputs {
+		if o.Error == "" && o.NearTimeLimit() {
+			res = append(res, o)
+		}
+	}
+	return res
+}
+
+// GenerateOutputs compiles the problem's reference solution and runs it on every test of the context,
+// writing its outputs down as the tests' expected outputs.
+// Tests that the reference solution fails on keep their outputs, and are listed in the report.
+func GenerateOutputs(s sandbox.Runner, g *GenerateContext) (*GenerateReport, error) {
+	p := g.Problem
+	if p.ReferenceSolution == "" {
+		return nil, errors.New("the problem has no reference solution")
+	}
+	if p.OutputOnly {
+		return nil, errors.New("output-only problems have no program to generate the outputs")
+	}
+	files, err := models.GetProblemFiles(g.DB, p.ID)
+	if err != nil {
+		return nil, err
+	}
+	var reference *models.File
+	for _, f := range files {
+		if f.Filename == p.ReferenceSolution {
+			reference = f
+		}
+		if f.Filename == InteractorFilename {
+			return nil, errors.New("the outputs of interactive problems cannot be generated")
+		}
+	}
+	if reference == nil {
+		return nil, errors.Errorf("the reference solution %s is not among the problem files", p.ReferenceSolution)
+	}
+	language, err := models.LanguageByExt(filepath.Ext(reference.Filename))
+	if err != nil {
+		return nil, errors.Wrapf(err, "reference solution %s", reference.Filename)
+	}
+
+	report := &GenerateReport{ReferenceSolution: p.ReferenceSolution}
+	sub := &models.Submission{ProblemID: p.ID, Language: language, Source: reference.Content}
+	compiled, err := CompileSubmission(s, sub, p.CompileFiles(files))
+	if err != nil {
+		return nil, err
+	}
+	if !compiled {
+		report.CompileError = string(sub.CompilerOutput)
+		return report, nil
+	}
+
+	for _, tg := range g.TestGroups {
+		for _, test := range tg.Tests {
+			r := &RunContext{DB: g.DB, Sub: sub, Problem: p, TestGroup: tg.TestGroup, Test: test}
+			if err := r.LoadFiles(); err != nil {
+				return nil, err
+			}
+			if err := r.LoadLimits(); err != nil {
+				return nil, err
+			}
+			generated, err := generateOutput(s, r)
+			if err != nil {
+				return nil, errors.Wrapf(err, "test %s", test.Name)
+			}
+			report.Outputs = append(report.Outputs, generated)
+			if generated.Error != "" {
+				continue
+			}
+			if _, err := g.DB.Exec("UPDATE tests SET output = ? WHERE id = ?", test.Output, test.ID); err != nil {
+				return nil, errors.WithStack(err)
+			}
+		}
+	}
+	return report, nil
+}
+
+// Runs the reference solution on the test, putting its output into the test.
+func generateOutput(s sandbox.Runner, r *RunContext) (*GeneratedOutput, error) {
+	log.Printf("[GENERATE] Running the reference solution of problem %v on [test `%v`, group `%v`]\n", r.Problem.ID, r.Test.Name, r.TestGroup.Name)
+	var (
+		output *sandbox.Output
+		err    error
+	)
+	if r.Stages == nil {
+		output, err = RunSingleCommand(s, r, r.Sub.CompiledSource)
+	} else {
+		output, err = RunMultipleCommands(s, r, r.Sub.CompiledSource, strings.Split(string(r.Stages.Content), "\n"))
+	}
+	if err != nil {
+		return nil, err
+	}
+	generated := &GeneratedOutput{
+		TestGroup:   r.TestGroup,
+		TestID:      r.Test.ID,
+		TestName:    r.Test.Name,
+		RunningTime: int(output.RunningTime / time.Millisecond),
+		MemoryUsed:  output.MemoryUsed,
+		TimeLimit:   int(r.TimeLimit() / time.Millisecond),
+	}
+	if !output.Success {
+		generated.Error = failureVerdictCode(output, r).Name()
+		if output.ErrorMessage != "" {
+			generated.Error += ": " + output.ErrorMessage
+		}
+		return generated, nil
+	}
+	content, ok := r.SubmissionOutput(output)
+	if !ok {
+		generated.Error = fmt.Sprintf("Output file `%s` not found", r.Problem.OutputFile)
+		return generated, nil
+	}
+	if content == nil {
+		content = []byte{}
+	}
+	r.Test.Output = content
+	return generated, nil
+}
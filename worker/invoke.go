@@ -0,0 +1,230 @@
+package worker
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/natsukagami/kjudge/db"
+	"github.com/natsukagami/kjudge/models"
+	"github.com/natsukagami/kjudge/worker/sandbox"
+	"github.com/pkg/errors"
+)
+
+// Model solutions (see models.ModelSolution) are judged like submissions in the background, on demand
+// (see models.AdminTaskTypeInvoke), and their results are checked against the outcomes they are expected to get.
+// No results are written to the database: they are only reported.
+
+// InvocationContext is the context needed to judge the model solutions of a problem.
+type InvocationContext struct {
+	DB        db.DBContext
+	Problem   *models.Problem
+	Solutions []*models.ModelSolution
+	// The test groups of the problem, with the tests' inputs and outputs.
+	TestGroups []*models.TestGroupWithTests
+}
+
+// InvocationCell is the result of a model solution on a test group.
+type InvocationCell struct {
+	TestGroup *models.TestGroup
+	Score     float64
+	// The verdict of the first test the solution did not pass, or Accepted.
+	Verdict     models.VerdictCode
+	RunningTime int // The longest running time over the tests, in milliseconds
+	MemoryUsed  int // The most memory used over the tests, in KBs
+	// Why the result differs from the expected outcome, empty if it does not.
+	Mismatch string
+}
+
+// InvocationRow is the result of a model solution on the whole problem.
+type InvocationRow struct {
+	// The model solution, without its source.
+	Solution *models.ModelSolution
+	// The solution's compiler output, if it failed to compile. No tests are run then.
+	CompileError string
+	Score        float64
+	Cells        []*InvocationCell
+	// Why the result differs from the expected outcome as a whole, empty if it does not (see Mismatched).
+	Mismatch string
+}
+
+// Mismatched returns whether the result differs from the expected outcome, on the whole or on any test group.
+func (r *InvocationRow) Mismatched() bool {
+	if r.Mismatch != "" {
+		return true
+	}
+	for _, c := range r.Cells {
+		if c.Mismatch != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// InvocationTestGroup is a test group of the problem, as a column of the report.
+type InvocationTestGroup struct {
+	*models.TestGroup
+	TestCount int
+}
+
+// InvocationReport is the report of judging the model solutions of a problem: a matrix of solutions and test groups.
+type InvocationReport struct {
+	TestGroups []*InvocationTestGroup
+	Rows       []*InvocationRow
+}
+
+// Mismatched returns the rows whose results differ from the expected outcomes.
+func (r *InvocationReport) Mismatched() []*InvocationRow {
+	var res []*InvocationRow
+	for _, row := range r.Rows {
+		if row.Mismatched() {
+			res = append(res, row)
+		}
+	}
+	return res
+}
+
+// Invoke judges every model solution of the context against the tests, and checks their expected outcomes.
+func Invoke(s sandbox.Runner, c *InvocationContext) (*InvocationReport, error) {
+	if c.Problem.OutputOnly {
+		return nil, errors.New("output-only problems have no programs to judge")
+	}
+	files, err := models.GetProblemFiles(c.DB, c.Problem.ID)
+	if err != nil {
+		return nil, err
+	}
+	report := &InvocationReport{}
+	for _, tg := range c.TestGroups {
+		report.TestGroups = append(report.TestGroups, &InvocationTestGroup{TestGroup: tg.TestGroup, TestCount: len(tg.Tests)})
+	}
+	for _, solution := range c.Solutions {
+		row, err := invokeSolution(s, c, solution, c.Problem.CompileFiles(files))
+		if err != nil {
+			return nil, errors.Wrapf(err, "model solution %s", solution.Name)
+		}
+		report.Rows = append(report.Rows, row)
+	}
+	return report, nil
+}
+
+// Judges the model solution like a submission, without writing anything down.
+func invokeSolution(s sandbox.Runner, c *InvocationContext, solution *models.ModelSolution, files []*models.File) (*InvocationRow, error) {
+	log.Printf("[INVOKE] Judging model solution %s of problem %v\n", solution.Name, c.Problem.ID)
+	meta := *solution
+	meta.Source = nil
+	row := &InvocationRow{Solution: &meta}
+	sub := &models.Submission{ProblemID: c.Problem.ID, Language: solution.Language, Source: solution.Source}
+	compiled, err := CompileSubmission(s, sub, files)
+	if err != nil {
+		return nil, err
+	}
+	if !compiled {
+		row.CompileError = string(sub.CompilerOutput)
+		row.Mismatch = "Compile Error"
+		return row, nil
+	}
+
+	testGroupOf := make(map[int]*models.TestGroup)
+	for _, tg := range c.TestGroups {
+		for _, test := range tg.Tests {
+			testGroupOf[test.ID] = tg.TestGroup
+		}
+	}
+	// Run the tests the way Score asks for them, skipping the rest of the groups that stop at their first failure.
+	results := make(map[int]*models.TestResult)
+	failedGroups := make(map[int]bool)
+	for {
+		missing, skipped := MissingTests(c.TestGroups, results)
+		for _, test := range skipped {
+			results[test.ID] = models.NewSkippedTestResult(0, test.ID)
+		}
+		if len(missing) == 0 {
+			break
+		}
+		for _, test := range missing {
+			if tg := testGroupOf[test.ID]; tg.StopsAtFirstFailure() && failedGroups[tg.ID] {
+				results[test.ID] = models.NewSkippedTestResult(0, test.ID)
+				continue
+			}
+			r := &RunContext{DB: c.DB, Sub: sub, Problem: c.Problem, TestGroup: testGroupOf[test.ID], Test: test}
+			if err := r.LoadFiles(); err != nil {
+				return nil, err
+			}
+			if err := r.LoadLimits(); err != nil {
+				return nil, err
+			}
+			result, err := RunTest(s, r)
+			if err != nil {
+				// Like a dead job, the test gets a Judge Error result.
+				log.Printf("[INVOKE] Test %s of model solution %s failed: %v\n", test.Name, solution.Name, err)
+				result = &models.TestResult{TestID: test.ID, Verdict: err.Error(), VerdictCode: models.VerdictCodeJudgeError}
+			}
+			results[test.ID] = result
+			if result.Failed() {
+				failedGroups[testGroupOf[test.ID].ID] = true
+			}
+		}
+	}
+
+	counts := make(map[models.VerdictCode]int)
+	for _, tg := range c.TestGroups {
+		cell := &InvocationCell{TestGroup: tg.TestGroup, Score: tg.ComputeScore(results), Verdict: models.VerdictCodeAccepted}
+		if _, r := models.FirstFailedTest([]*models.TestGroupWithTests{tg}, results); r != nil {
+			cell.Verdict = r.VerdictCode
+		}
+		for _, test := range tg.Tests {
+			r := results[test.ID]
+			counts[r.VerdictCode]++
+			if cell.RunningTime < r.RunningTime {
+				cell.RunningTime = r.RunningTime
+			}
+			if cell.MemoryUsed < r.MemoryUsed {
+				cell.MemoryUsed = r.MemoryUsed
+			}
+			if r.VerdictCode == models.VerdictCodeJudgeError && cell.Mismatch == "" {
+				cell.Mismatch = fmt.Sprintf("Judge Error on test %s: %s", test.Name, r.Verdict)
+			}
+			if cell.Mismatch == "" {
+				cell.Mismatch = mismatchOf(solution, r.VerdictCode)
+			}
+		}
+		if cell.Mismatch == "" && solution.Expected == models.ExpectedOutcomeAccepted && cell.Score != tg.Score {
+			cell.Mismatch = fmt.Sprintf("Expected %v points", tg.Score)
+		}
+		if solution.Expected == models.ExpectedOutcomeScores && !solution.ExpectedScores.Matches(tg.Name, cell.Score) {
+			cell.Mismatch = fmt.Sprintf("Expected %v points", solution.ExpectedScores[tg.Name])
+		}
+		row.Score += cell.Score
+		row.Cells = append(row.Cells, cell)
+	}
+	switch {
+	case solution.Expected == models.ExpectedOutcomeWrongAnswer &&
+		counts[models.VerdictCodeWrongAnswer]+counts[models.VerdictCodePartiallyAccepted] == 0:
+		row.Mismatch = "No test got a wrong answer"
+	case solution.Expected == models.ExpectedOutcomeTimeLimit && counts[models.VerdictCodeTimeLimitExceeded] == 0:
+		row.Mismatch = "No test ran out of time"
+	}
+	return row, nil
+}
+
+// Returns why the verdict of a test differs from the solution's expected outcome, or "" if it does not.
+func mismatchOf(solution *models.ModelSolution, verdict models.VerdictCode) string {
+	switch verdict {
+	case models.VerdictCodeAccepted, models.VerdictCodeSkipped:
+		return ""
+	case models.VerdictCodeJudgeError:
+		return "Judge Error"
+	}
+	switch solution.Expected {
+	case models.ExpectedOutcomeAccepted:
+		return "Expected Accepted, got " + verdict.Name()
+	case models.ExpectedOutcomeWrongAnswer:
+		if verdict != models.VerdictCodeWrongAnswer && verdict != models.VerdictCodePartiallyAccepted {
+			return "Expected Wrong Answer, got " + verdict.Name()
+		}
+	case models.ExpectedOutcomeTimeLimit:
+		if verdict != models.VerdictCodeTimeLimitExceeded {
+			return "Expected Time Limit Exceeded, got " + verdict.Name()
+		}
+	}
+	return ""
+}
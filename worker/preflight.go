@@ -0,0 +1,285 @@
+package worker
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"strings"
+
+	"github.com/natsukagami/kjudge/db"
+	"github.com/natsukagami/kjudge/models"
+	"github.com/pkg/errors"
+)
+
+// The pre-flight check looks for the mistakes in a contest's setup that are otherwise only found once the
+// contestants submit, e.g. an empty test group or a checker that was never compiled.
+// Errors make the problem unjudgeable (or judged wrongly), while warnings might be intended.
+// Contests can be held until their pre-flight check finds no errors (see models.Contest.PreflightHold).
+
+// PreflightSeverity is how bad a problem found by the pre-flight check is.
+type PreflightSeverity string
+
+// All possible values of PreflightSeverity.
+const (
+	PreflightError   PreflightSeverity = "error"
+	PreflightWarning PreflightSeverity = "warning"
+)
+
+// PreflightIssue is a problem found by the pre-flight check.
+type PreflightIssue struct {
+	Severity PreflightSeverity
+	// The problem and the test group the issue is about, nil if it is about the contest or the whole problem.
+	Problem   *models.Problem
+	TestGroup *models.TestGroup
+	Message   string
+}
+
+// PreflightReport is the result of the pre-flight check of a contest.
+type PreflightReport struct {
+	Contest *models.Contest
+	Issues  []*PreflightIssue
+}
+
+// Errors returns the issues that are errors.
+func (r *PreflightReport) Errors() []*PreflightIssue {
+	return r.filter(PreflightError)
+}
+
+// Warnings returns the issues that are warnings.
+func (r *PreflightReport) Warnings() []*PreflightIssue {
+	return r.filter(PreflightWarning)
+}
+
+func (r *PreflightReport) filter(severity PreflightSeverity) []*PreflightIssue {
+	var res []*PreflightIssue
+	for _, issue := range r.Issues {
+		if issue.Severity == severity {
+			res = append(res, issue)
+		}
+	}
+	return res
+}
+
+// Held returns whether the errors of the report hold the contest from starting.
+// Contests are only checked when they start (see models.Contest.Held) and when their hold is released.
+func (r *PreflightReport) Held() bool {
+	return r.Contest.PreflightHold && len(r.Errors()) > 0
+}
+
+// The problem being checked, with everything the rules need.
+type preflightProblem struct {
+	*models.Problem
+	Contest    *models.Contest
+	TestGroups []*models.TestGroupWithTests
+	// The problem files (without their content), by their filename.
+	Files map[string]*models.File
+	// The first byte of the expected output of each test, by its ID.
+	OutputPreviews map[int][]byte
+	// The ".stages" file with its content, nil if there is none.
+	Stages *models.File
+
+	issues []*PreflightIssue
+}
+
+func (p *preflightProblem) add(severity PreflightSeverity, tg *models.TestGroup, format string, args ...interface{}) {
+	p.issues = append(p.issues, &PreflightIssue{
+		Severity:  severity,
+		Problem:   p.Problem,
+		TestGroup: tg,
+		Message:   fmt.Sprintf(format, args...),
+	})
+}
+
+// A rule of the pre-flight check, adding the issues it finds to the problem.
+type preflightRule func(p *preflightProblem)
+
+// The rules checked on every problem, in order.
+var preflightRules = []preflightRule{
+	checkTestGroups,
+	checkScores,
+	checkCompiledFiles,
+	checkStages,
+	checkOutputs,
+	checkValidation,
+	checkLanguages,
+	checkStatements,
+}
+
+// Preflight runs the pre-flight check of the contest.
+func Preflight(db db.DBContext, contest *models.Contest) (*PreflightReport, error) {
+	report := &PreflightReport{Contest: contest}
+	problems, err := models.GetContestProblems(db, contest.ID)
+	if err != nil {
+		return nil, err
+	}
+	if len(problems) == 0 {
+		report.Issues = append(report.Issues, &PreflightIssue{Severity: PreflightError, Message: "The contest has no problems."})
+	}
+	for _, problem := range problems {
+		p, err := loadPreflightProblem(db, contest, problem)
+		if err != nil {
+			return nil, errors.Wrapf(err, "problem %s", problem.Name)
+		}
+		for _, rule := range preflightRules {
+			rule(p)
+		}
+		report.Issues = append(report.Issues, p.issues...)
+	}
+	return report, nil
+}
+
+func loadPreflightProblem(db db.DBContext, contest *models.Contest, problem *models.Problem) (*preflightProblem, error) {
+	p := &preflightProblem{Problem: problem, Contest: contest, Files: make(map[string]*models.File)}
+	var err error
+	if p.TestGroups, err = models.GetProblemTestsMeta(db, problem.ID); err != nil {
+		return nil, err
+	}
+	if p.OutputPreviews, err = models.GetProblemTestOutputPreviews(db, problem.ID, 1); err != nil {
+		return nil, err
+	}
+	files, err := models.GetProblemFilesMeta(db, problem.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range files {
+		p.Files[f.Filename] = f
+	}
+	if _, ok := p.Files[".stages"]; ok {
+		if p.Stages, err = models.GetFileWithName(db, problem.ID, ".stages"); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+// Every test group needs tests, unless it only shares the tests of its dependencies.
+func checkTestGroups(p *preflightProblem) {
+	if len(p.TestGroups) == 0 {
+		p.add(PreflightError, nil, "The problem has no test groups.")
+	}
+	for _, tg := range p.TestGroups {
+		if len(tg.Tests) == 0 && len(tg.Dependencies) == 0 {
+			p.add(PreflightError, tg.TestGroup, "The test group has no tests.")
+		}
+	}
+}
+
+// The scores of the test groups usually sum up to 100.
+func checkScores(p *preflightProblem) {
+	if len(p.TestGroups) == 0 {
+		return
+	}
+	total := 0.0
+	for _, tg := range p.TestGroups {
+		total += tg.Score
+	}
+	if math.Abs(total-100) > 1e-6 {
+		p.add(PreflightWarning, nil, "The scores of the test groups sum up to %v, not 100.", total)
+	}
+}
+
+// The binaries of the judge are compiled from their sources by the admins, who might forget to.
+func checkCompiledFiles(p *preflightProblem) {
+	for _, binary := range []string{CompareFilename, CheckerFilename, InteractorFilename, ValidatorFilename} {
+		if _, ok := p.Files[binary]; ok {
+			continue
+		}
+		for name, f := range p.Files {
+			if f.Compilable() && strings.TrimSuffix(name, filepath.Ext(name)) == binary {
+				p.add(PreflightError, nil, "`%s` was never compiled into `%s`, which is used by the judge.", name, binary)
+			}
+		}
+	}
+}
+
+// Blank stages (other than after the last line break) run the submission without arguments.
+func checkStages(p *preflightProblem) {
+	if p.Stages == nil {
+		return
+	}
+	stages := strings.Split(string(p.Stages.Content), "\n")
+	for i, stage := range stages {
+		if strings.TrimSpace(stage) == "" && i != len(stages)-1 {
+			p.add(PreflightError, nil, "Stage %d of `.stages` is blank.", i+1)
+		}
+	}
+}
+
+// Tests with no expected output were most likely uploaded without one.
+func checkOutputs(p *preflightProblem) {
+	if _, ok := p.Files[InteractorFilename]; ok {
+		return
+	}
+	for _, tg := range p.TestGroups {
+		var empty []string
+		for _, test := range tg.Tests {
+			if len(p.OutputPreviews[test.ID]) == 0 {
+				empty = append(empty, test.Name)
+			}
+		}
+		if len(empty) > 0 {
+			p.add(PreflightWarning, tg.TestGroup, "%d test(s) have an empty expected output: %s.", len(empty), strings.Join(empty, ", "))
+		}
+	}
+}
+
+// Tests rejected by the validator are broken, and the ones not validated (or that the validator failed on) might be.
+func checkValidation(p *preflightProblem) {
+	if _, ok := p.Files[ValidatorFilename]; !ok {
+		return
+	}
+	for _, tg := range p.TestGroups {
+		summary := models.SummarizeValidation([]*models.TestGroupWithTests{tg})
+		for _, test := range summary.Invalid {
+			p.add(PreflightError, tg.TestGroup, "Test %s was rejected by the validator: %s", test.Name, test.ValidationMessage)
+		}
+		for _, test := range summary.Failed {
+			p.add(PreflightWarning, tg.TestGroup, "The validator failed on test %s: %s", test.Name, test.ValidationMessage)
+		}
+		if summary.NotValidated > 0 {
+			p.add(PreflightWarning, tg.TestGroup, "%d test(s) were not validated.", summary.NotValidated)
+		}
+	}
+}
+
+// Contestants need a language to submit in.
+func checkLanguages(p *preflightProblem) {
+	if p.OutputOnly {
+		return
+	}
+	languages := p.Languages.Filter(p.Contest.Languages.Filter(models.AvailableLanguages()))
+	if len(languages) == 0 {
+		p.add(PreflightError, nil, "No language is accepted: the languages of the problem and the contest do not overlap with the available ones.")
+		return
+	}
+	var files []*models.File
+	for _, f := range p.Files {
+		files = append(files, f)
+	}
+	graders := models.Graders(files)
+	if len(graders) == 0 {
+		return
+	}
+	for _, l := range languages {
+		if _, ok := graders[l.ID]; ok && l.SupportsGraders() {
+			return
+		}
+	}
+	p.add(PreflightError, nil, "No language is accepted: none of the accepted languages has a grader.")
+}
+
+// Contestants need the statements.
+func checkStatements(p *preflightProblem) {
+	found := false
+	for _, name := range []string{"statements.pdf", "statements.md"} {
+		if f, ok := p.Files[name]; ok {
+			found = true
+			if !f.Public {
+				p.add(PreflightWarning, nil, "`%s` is not public, the contestants cannot see it.", name)
+			}
+		}
+	}
+	if !found {
+		p.add(PreflightWarning, nil, "The problem has no statements (`statements.pdf` or `statements.md`).")
+	}
+}
@@ -1,6 +1,7 @@
 package worker
 
 import (
+	"fmt"
 	"log"
 	"time"
 
@@ -11,89 +12,253 @@ import (
 	"github.com/pkg/errors"
 )
 
-// Queue implements a queue that runs each job one by one.
+// Queue implements a queue that runs jobs on a pool of workers.
+// When no jobs are ready, the workers run the admins' tasks (see models.AdminTask).
 type Queue struct {
 	DB      *db.DB
 	Sandbox sandbox.Runner
+	// The number of workers running jobs in parallel, each in its own sandbox box.
+	// Defaults to 1.
+	Workers int
+	// Whether the queue only runs the admins' tasks on a single worker, leaving the jobs to remote workers.
+	AdminTasksOnly bool
+	// The amount of the submissions' output kept on each test, in KBs.
+	KeptOutput int
 }
 
 // Start starts the queue. It is blocking, so might wanna "go run" it.
 func (q *Queue) Start() {
 	// Register the update callback
 	toUpdate := q.startHook()
+	// The tasks claimed before the queue (re)started are not run by anyone anymore.
+	if err := models.ReleaseAdminTasks(q.DB); err != nil {
+		log.Printf("[WORKER] Releasing admin tasks failed: %+v\n", err)
+	}
+	workers := q.Workers
+	if workers < 1 || q.AdminTasksOnly {
+		workers = 1
+	}
+	for id := 1; id < workers; id++ {
+		w := &Queue{DB: q.DB, Sandbox: q.Sandbox.Box(id), KeptOutput: q.KeptOutput}
+		go w.work(id, toUpdate)
+	}
+	w := &Queue{DB: q.DB, Sandbox: q.Sandbox.Box(0), KeptOutput: q.KeptOutput, AdminTasksOnly: q.AdminTasksOnly}
+	w.work(0, toUpdate)
+}
+
+// Claims a job, unless the queue only runs the admins' tasks. Returns nil if there is none.
+func (q *Queue) claimJob(owner string) (*models.Job, error) {
+	if q.AdminTasksOnly {
+		return nil, nil
+	}
+	return models.ClaimJob(q.DB, owner)
+}
+
+// Takes and runs jobs one by one.
+func (q *Queue) work(id int, toUpdate chan struct{}) {
+	owner := fmt.Sprintf("worker-%d", id)
 	for {
 		// Get the newest job
-		job, err := models.FirstJob(q.DB)
+		job, err := q.claimJob(owner)
 		if err != nil {
-			log.Printf("[WORKER] Fetching job failed: %+v\n", err)
+			log.Printf("[WORKER %d] Fetching job failed: %+v\n", id, err)
+			<-toUpdate
 			continue
 		}
 		if job == nil {
+			if q.adminTask(id, owner, toUpdate) {
+				continue
+			}
 			// Wait for at least one toUpdate before continuing
 			<-toUpdate
 			continue
 		}
+		// There might be more jobs ready, let another idle worker look.
+		notify(toUpdate)
 
-		if err := q.HandleJob(job); err != nil {
-			log.Printf("[WORKER] Handling job failed: %+v\n", err)
+		stopLease := q.keepLease(id, job)
+		err = q.HandleJob(job)
+		stopLease()
+		if errors.Is(err, models.ErrJobLost) {
+			log.Printf("[WORKER %d] Lost the claim on job %d, throwing its results away\n", id, job.ID)
+		} else if err != nil {
+			log.Printf("[WORKER %d] Handling job failed (attempt %d/%d): %+v\n", id, job.Attempts, models.MaxJobAttempts, err)
+			if err := job.Fail(q.DB, err); err != nil {
+				log.Printf("[WORKER %d] Recording job failure failed: %+v\n", id, err)
+			}
 		}
-		_ = job.Delete(q.DB)
 	}
 }
 
-// HandleJob dispatches a job.
-func (q *Queue) HandleJob(job *models.Job) error {
-	// Start a job with a context and submission
-	tx, err := q.DB.Beginx()
+// Runs an admin task, if there is one. Returns whether there was.
+func (q *Queue) adminTask(id int, owner string, toUpdate chan struct{}) bool {
+	task, err := models.ClaimAdminTask(q.DB, owner)
 	if err != nil {
-		return errors.WithStack(err)
+		log.Printf("[WORKER %d] Fetching admin task failed: %+v\n", id, err)
+		return false
 	}
-	defer db.Rollback(tx)
+	if task == nil {
+		return false
+	}
+	notify(toUpdate)
+	if err := q.HandleAdminTask(task); err != nil {
+		log.Printf("[WORKER %d] Recording admin task %d failed: %+v\n", id, task.ID, err)
+	}
+	return true
+}
+
+// Keeps extending the claim on the job until the returned function is called.
+func (q *Queue) keepLease(id int, job *models.Job) func() {
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(models.JobLeaseDuration / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				err := job.ExtendLease(q.DB)
+				if errors.Is(err, models.ErrJobLost) {
+					// The results are thrown away when the job is finished, so stop keeping the claim.
+					log.Printf("[WORKER %d] Lost the claim on job %d\n", id, job.ID)
+					return
+				} else if err != nil {
+					log.Printf("[WORKER %d] Extending job lease failed: %+v\n", id, err)
+				}
+			}
+		}
+	}()
+	return func() {
+		close(stop)
+		<-done
+	}
+}
 
-	sub, err := models.GetSubmission(tx, job.SubmissionID)
+// HandleJob dispatches a job, and finishes it.
+// The results are only written down if the job is still claimed by its claimer (see FinishJob),
+// so a worker that lost its claim cannot overwrite the results of the job's new claimer.
+func (q *Queue) HandleJob(job *models.Job) error {
+	sub, err := models.GetSubmission(q.DB, job.SubmissionID)
 	if err != nil {
 		return err
 	}
-	problem, err := models.GetProblem(tx, sub.ProblemID)
+	problem, err := models.GetProblem(q.DB, sub.ProblemID)
 	if err != nil {
 		return err
 	}
+	// Compiling and running take a long time, so they are not done inside a transaction,
+	// which would block other workers from writing to the database.
 	switch job.Type {
 	case models.JobTypeCompile:
-		if _, err := Compile(&CompileContext{DB: tx, Sub: sub, Problem: problem}); err != nil {
+		files, err := models.GetProblemFiles(q.DB, problem.ID)
+		if err != nil {
+			return err
+		}
+		if _, err := CompileSubmission(q.Sandbox, sub, problem.CompileFiles(files)); err != nil {
 			return err
 		}
+		return FinishJob(q.DB, job, sub.Write)
 	case models.JobTypeRun:
-		test, err := models.GetTest(tx, int(job.TestID.Int64))
+		test, err := models.GetTest(q.DB, int(job.TestID.Int64))
 		if err != nil {
 			return err
 		}
-		tg, err := models.GetTestGroup(tx, test.TestGroupID)
+		tg, err := models.GetTestGroup(q.DB, test.TestGroupID)
 		if err != nil {
 			return err
 		}
-		if err := Run(q.Sandbox, &RunContext{
-			DB: tx, Sub: sub, Problem: problem, TestGroup: tg, Test: test}); err != nil {
+		r := &RunContext{DB: q.DB, Sub: sub, Problem: problem, TestGroup: tg, Test: test, KeptOutput: q.KeptOutput}
+		compiled, source := r.CompiledSource()
+		if !compiled {
+			// Add a compilation job and re-add ourselves.
+			log.Printf("[WORKER] Submission %v not compiled, creating Compile job.\n", sub.ID)
+			return FinishJob(q.DB, job, func(db db.DBContext) error {
+				return models.BatchInsertJobs(db, models.NewJobCompile(sub.ID), models.NewJobRun(sub.ID, test.ID))
+			})
+		}
+		if source == nil {
+			log.Printf("[WORKER] Not running a submission that failed to compile.\n")
+			return FinishJob(q.DB, job, nil)
+		}
+		if err := r.LoadFiles(); err != nil {
 			return err
 		}
+		if err := r.LoadLimits(); err != nil {
+			return err
+		}
+		result, err := RunTest(q.Sandbox, r)
+		if err != nil {
+			return err
+		}
+		return FinishJob(q.DB, job, func(db db.DBContext) error {
+			if err := result.Write(db); err != nil {
+				return err
+			}
+			if tg.StopsAtFirstFailure() && result.Failed() {
+				// The remaining tests of the group are skipped when scoring.
+				return models.CancelGroupRunJobs(db, sub.ID, tg.ID)
+			}
+			return nil
+		})
 	case models.JobTypeScore:
+		tx, err := q.DB.Beginx()
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		defer db.Rollback(tx)
+
 		contest, err := models.GetContest(tx, problem.ContestID)
 		if err != nil {
 			return err
 		}
+		if err := job.Finish(tx); err != nil {
+			return err
+		}
 		if err := Score(&ScoreContext{DB: tx, Sub: sub, Problem: problem, Contest: contest}); err != nil {
 			return err
 		}
+		return errors.WithStack(tx.Commit())
+	}
+	return nil
+}
+
+// FinishJob finishes the job, writing down its results with `write` (if not nil) in the same transaction.
+// Returns models.ErrJobLost, writing nothing, if the job is not claimed by its claimer anymore.
+func FinishJob(d *db.DB, job *models.Job, write func(db db.DBContext) error) error {
+	tx, err := d.Beginx()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer db.Rollback(tx)
+
+	if err := job.Finish(tx); err != nil {
+		return err
+	}
+	if write != nil {
+		if err := write(tx); err != nil {
+			return err
+		}
 	}
 	return errors.WithStack(tx.Commit())
 }
 
-// Starts a hook to be announced everytime jobs is inserted.
-func (q *Queue) startHook() <-chan struct{} {
+// Wakes up a waiting worker, if there is any.
+func notify(toUpdate chan<- struct{}) {
+	select {
+	case toUpdate <- struct{}{}:
+	default:
+	}
+}
+
+// Starts a hook to be announced everytime jobs or admin tasks are inserted.
+func (q *Queue) startHook() chan struct{} {
 	toUpdate := make(chan struct{})
 	q.DB.PersistentConn.RegisterUpdateHook(func(typ int, db, table string, rowID int64) {
-		if typ == sqlite3.SQLITE_INSERT && table == "jobs" {
-			toUpdate <- struct{}{}
+		if typ == sqlite3.SQLITE_INSERT && (table == "jobs" || table == "admin_tasks") {
+			notify(toUpdate)
 		}
 	})
 	go func() {
@@ -0,0 +1,131 @@
+package remote
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/natsukagami/kjudge/models"
+	"github.com/pkg/errors"
+)
+
+// Client talks to the main server on behalf of a remote worker.
+type Client struct {
+	// The root URL of the main server, e.g. "http://10.0.0.1:8088".
+	Server string
+	Token  string
+	// The worker's name, shown on the main server.
+	Name    string
+	Sandbox string
+
+	HTTP *http.Client
+}
+
+// NewClient creates a new Client.
+func NewClient(server, token, name, sandbox string) *Client {
+	return &Client{
+		Server:  strings.TrimSuffix(server, "/"),
+		Token:   token,
+		Name:    name,
+		Sandbox: sandbox,
+		HTTP:    &http.Client{Timeout: 5 * time.Minute},
+	}
+}
+
+// Sends a request to the server, decoding the JSON response into `result` if it is not nil.
+// Returns the response's status code.
+func (c *Client) do(method, path string, body interface{}, result interface{}) (int, error) {
+	var reader io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return 0, errors.WithStack(err)
+		}
+		reader = bytes.NewReader(b)
+	}
+	req, err := http.NewRequest(method, c.Server+"/worker"+path, reader)
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	req.Header.Set(TokenHeader, "Bearer "+c.Token)
+	req.Header.Set(NameHeader, c.Name)
+	req.Header.Set(SandboxHeader, c.Sandbox)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	res, err := c.HTTP.Do(req)
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	defer res.Body.Close()
+	switch {
+	case res.StatusCode == http.StatusConflict:
+		return res.StatusCode, ErrJobLost
+	case res.StatusCode >= 400:
+		return res.StatusCode, errors.Errorf("%s %s: server answered %s", method, path, res.Status)
+	}
+	if result == nil || res.StatusCode == http.StatusNoContent {
+		return res.StatusCode, nil
+	}
+	if b, ok := result.(*[]byte); ok {
+		*b, err = io.ReadAll(res.Body)
+		return res.StatusCode, errors.WithStack(err)
+	}
+	return res.StatusCode, errors.WithStack(json.NewDecoder(res.Body).Decode(result))
+}
+
+// Claim claims a job. Returns nil if no jobs are ready.
+func (c *Client) Claim() (*Task, error) {
+	var task Task
+	status, err := c.do(http.MethodPost, "/claim", nil, &task)
+	if err != nil || status == http.StatusNoContent {
+		return nil, err
+	}
+	return &task, nil
+}
+
+// Heartbeat keeps the claim on the job alive.
+func (c *Client) Heartbeat(jobID int) error {
+	_, err := c.do(http.MethodPost, fmt.Sprintf("/jobs/%d/heartbeat", jobID), nil, nil)
+	return err
+}
+
+// FinishCompile pushes the result of a compile job.
+func (c *Client) FinishCompile(jobID int, result *CompileResult) error {
+	_, err := c.do(http.MethodPost, fmt.Sprintf("/jobs/%d/compile", jobID), result, nil)
+	return err
+}
+
+// FinishRun pushes the result of a run job.
+func (c *Client) FinishRun(jobID int, result *models.TestResult) error {
+	_, err := c.do(http.MethodPost, fmt.Sprintf("/jobs/%d/run", jobID), result, nil)
+	return err
+}
+
+// Fail reports that the job could not be done.
+func (c *Client) Fail(jobID int, cause error) error {
+	_, err := c.do(http.MethodPost, fmt.Sprintf("/jobs/%d/fail", jobID), &FailRequest{Error: cause.Error()}, nil)
+	return err
+}
+
+// CompiledSource fetches the compiled binary of the submission.
+func (c *Client) CompiledSource(subID int) ([]byte, error) {
+	var b []byte
+	_, err := c.do(http.MethodGet, fmt.Sprintf("/submissions/%d/compiled", subID), nil, &b)
+	return b, err
+}
+
+// TestData fetches the input and the expected output of the test.
+func (c *Client) TestData(testID int) (input, output []byte, err error) {
+	if _, err := c.do(http.MethodGet, fmt.Sprintf("/tests/%d/input", testID), nil, &input); err != nil {
+		return nil, nil, err
+	}
+	if _, err := c.do(http.MethodGet, fmt.Sprintf("/tests/%d/output", testID), nil, &output); err != nil {
+		return nil, nil, err
+	}
+	return input, output, nil
+}
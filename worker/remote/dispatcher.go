@@ -0,0 +1,204 @@
+package remote
+
+import (
+	"database/sql"
+	"log"
+
+	"github.com/natsukagami/kjudge/db"
+	"github.com/natsukagami/kjudge/models"
+	"github.com/natsukagami/kjudge/worker"
+	"github.com/pkg/errors"
+)
+
+// ErrJobLost is returned when a worker reports on a job it does not hold the claim of anymore,
+// usually because its lease has expired and the job went to someone else.
+var ErrJobLost = models.ErrJobLost
+
+// Dispatcher hands out jobs to remote workers and records their results, on the main server.
+type Dispatcher struct {
+	DB *db.DB
+	// The amount of the submissions' output kept on each test, in KBs.
+	KeptOutput int
+}
+
+// Claim claims a job for the remote worker `owner`.
+// Jobs that only need the database (score jobs, or runs of submissions that are not compiled yet) are done
+// right here, so the returned task always needs a sandbox. Returns nil if no jobs are ready.
+func (d *Dispatcher) Claim(owner string) (*Task, error) {
+	for {
+		job, err := models.ClaimJob(d.DB, owner)
+		if err != nil || job == nil {
+			return nil, err
+		}
+		task, err := d.prepare(job)
+		if err != nil {
+			log.Printf("[REMOTE] Preparing job %d for %s failed: %+v\n", job.ID, owner, err)
+			if err := job.Fail(d.DB, err); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if task != nil {
+			return task, nil
+		}
+	}
+}
+
+// Prepares the task for the job. Returns nil if the job has been done (and finished) already.
+func (d *Dispatcher) prepare(job *models.Job) (*Task, error) {
+	if job.Type == models.JobTypeScore {
+		// Score jobs only need the database.
+		q := &worker.Queue{DB: d.DB}
+		return nil, q.HandleJob(job)
+	}
+	sub, err := models.GetSubmission(d.DB, job.SubmissionID)
+	if err != nil {
+		return nil, err
+	}
+	problem, err := models.GetProblem(d.DB, sub.ProblemID)
+	if err != nil {
+		return nil, err
+	}
+	if job.Type == models.JobTypeCompile {
+		files, err := models.GetProblemFiles(d.DB, problem.ID)
+		if err != nil {
+			return nil, err
+		}
+		return &Task{JobID: job.ID, Compile: &CompileTask{Submission: sub, Files: problem.CompileFiles(files)}}, nil
+	}
+
+	test, err := models.GetTest(d.DB, int(job.TestID.Int64))
+	if err != nil {
+		return nil, err
+	}
+	tg, err := models.GetTestGroup(d.DB, test.TestGroupID)
+	if err != nil {
+		return nil, err
+	}
+	r := &worker.RunContext{DB: d.DB, Sub: sub, Problem: problem, TestGroup: tg, Test: test, KeptOutput: d.KeptOutput}
+	compiled, source := r.CompiledSource()
+	if !compiled {
+		// Add a compilation job and re-add ourselves.
+		return nil, worker.FinishJob(d.DB, job, func(db db.DBContext) error {
+			return models.BatchInsertJobs(db, models.NewJobCompile(sub.ID), models.NewJobRun(sub.ID, test.ID))
+		})
+	}
+	if source == nil {
+		// Not running a submission that failed to compile.
+		return nil, worker.FinishJob(d.DB, job, nil)
+	}
+	if err := r.LoadFiles(); err != nil {
+		return nil, err
+	}
+	if err := r.LoadLimits(); err != nil {
+		return nil, err
+	}
+	return &Task{JobID: job.ID, Run: NewRunTask(r)}, nil
+}
+
+// Returns the job if it is still claimed by `owner`.
+func ownedJob(db db.DBContext, jobID int, owner string) (*models.Job, error) {
+	job, err := models.GetJob(db, jobID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrJobLost
+	} else if err != nil {
+		return nil, err
+	}
+	if job.ClaimedBy.String != owner {
+		return nil, ErrJobLost
+	}
+	return job, nil
+}
+
+// Heartbeat extends the claim of `owner` on the job.
+func (d *Dispatcher) Heartbeat(jobID int, owner string) error {
+	job, err := ownedJob(d.DB, jobID, owner)
+	if err != nil {
+		return err
+	}
+	return job.ExtendLease(d.DB)
+}
+
+// FinishCompile records the result of a compile job done by `owner`.
+func (d *Dispatcher) FinishCompile(jobID int, owner string, result *CompileResult) error {
+	tx, err := d.DB.Beginx()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer db.Rollback(tx)
+
+	job, err := ownedJob(tx, jobID, owner)
+	if err != nil {
+		return err
+	}
+	if job.Type != models.JobTypeCompile {
+		return errors.Errorf("job %d is not a compile job", job.ID)
+	}
+	sub, err := models.GetSubmission(tx, job.SubmissionID)
+	if err != nil {
+		return err
+	}
+	sub.CompilerOutput = result.CompilerOutput
+	if result.Succeeded {
+		sub.CompiledSource = result.CompiledSource
+	} else {
+		sub.CompiledSource = nil
+		sub.Verdict = models.VerdictCompileError
+	}
+	if err := sub.Write(tx); err != nil {
+		return err
+	}
+	if err := job.Finish(tx); err != nil {
+		return err
+	}
+	return errors.WithStack(tx.Commit())
+}
+
+// FinishRun records the result of a run job done by `owner`.
+func (d *Dispatcher) FinishRun(jobID int, owner string, result *models.TestResult) error {
+	tx, err := d.DB.Beginx()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer db.Rollback(tx)
+
+	job, err := ownedJob(tx, jobID, owner)
+	if err != nil {
+		return err
+	}
+	if job.Type != models.JobTypeRun {
+		return errors.Errorf("job %d is not a run job", job.ID)
+	}
+	test, err := models.GetTest(tx, int(job.TestID.Int64))
+	if err != nil {
+		return err
+	}
+	tg, err := models.GetTestGroup(tx, test.TestGroupID)
+	if err != nil {
+		return err
+	}
+	result.SubmissionID = job.SubmissionID
+	result.TestID = test.ID
+	if err := result.Write(tx); err != nil {
+		return err
+	}
+	if tg.StopsAtFirstFailure() && result.Failed() {
+		// The remaining tests of the group are skipped when scoring.
+		if err := models.CancelGroupRunJobs(tx, job.SubmissionID, tg.ID); err != nil {
+			return err
+		}
+	}
+	if err := job.Finish(tx); err != nil {
+		return err
+	}
+	return errors.WithStack(tx.Commit())
+}
+
+// Fail records that `owner` could not do the job.
+func (d *Dispatcher) Fail(jobID int, owner string, cause string) error {
+	job, err := ownedJob(d.DB, jobID, owner)
+	if err != nil {
+		return err
+	}
+	return job.Fail(d.DB, errors.New(cause))
+}
@@ -0,0 +1,83 @@
+package remote
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/natsukagami/kjudge/db"
+	"github.com/natsukagami/kjudge/models"
+)
+
+func TestFinishRunCancelsGroup(t *testing.T) {
+	d, err := db.New(filepath.Join(t.TempDir(), "kjudge.db"))
+	if err != nil {
+		t.Fatalf("creating database: %+v", err)
+	}
+	t.Cleanup(func() { d.Close() })
+	must := func(err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatalf("%+v", err)
+		}
+	}
+	contest := &models.Contest{Name: "c", StartTime: time.Now(), EndTime: time.Now().Add(time.Hour),
+		ContestType: models.ContestTypeWeighted, ScoreboardViewStatus: models.ScoreboardViewStatusPublic}
+	must(contest.Write(d))
+	problem := &models.Problem{ContestID: contest.ID, Name: "A", DisplayName: "A", TimeLimit: 1000, MemoryLimit: 262144,
+		ScoringMode: models.ScoringModeBest, PenaltyPolicy: models.PenaltyPolicyNone, ComparisonMode: models.ComparisonModeDiff,
+		FeedbackLevel: models.FeedbackLevelFull, OutputPattern: "output?.txt"}
+	must(problem.Write(d))
+	must((&models.User{ID: "u", Password: "x", DisplayName: "u"}).Write(d))
+
+	// Each submission runs the tests of one test group: one that stops at its first failure, and one that does not.
+	stopping := &models.TestGroup{ProblemID: problem.ID, Name: "min", Score: 50, ScoringMode: models.TestScoringModeMin,
+		SkipAfterFailure: true, Visibility: models.TestGroupVisibilityVisible}
+	sum := &models.TestGroup{ProblemID: problem.ID, Name: "sum", Score: 50, ScoringMode: models.TestScoringModeSum,
+		SkipAfterFailure: true, Visibility: models.TestGroupVisibilityVisible}
+	groupOf := make(map[int]*models.TestGroup)
+	subOf := make(map[int]*models.Submission)
+	for _, tg := range []*models.TestGroup{stopping, sum} {
+		must(tg.Write(d))
+		sub := &models.Submission{ProblemID: problem.ID, UserID: "u", SubmittedAt: time.Now(), Language: "g++",
+			Source: []byte("int main() {}"), CompiledSource: []byte("binary"), CompilerOutput: []byte{},
+			Verdict: models.VerdictIsInQueue}
+		must(sub.Write(d))
+		subOf[tg.ID] = sub
+		for _, name := range []string{"1", "2", "3"} {
+			test := &models.Test{TestGroupID: tg.ID, Name: name, Input: []byte("1\n"), Output: []byte("1\n")}
+			must(test.Write(d))
+			groupOf[test.ID] = tg
+			must(models.NewJobRun(sub.ID, test.ID).Write(d))
+		}
+	}
+	runJobs := func(sub *models.Submission) int {
+		t.Helper()
+		var count int
+		must(d.Get(&count, "SELECT COUNT(*) FROM jobs WHERE type = ? AND submission_id = ?", models.JobTypeRun, sub.ID))
+		return count
+	}
+
+	dispatcher := &Dispatcher{DB: d}
+	for i := 0; i < 2; i++ {
+		task, err := dispatcher.Claim("remote")
+		if err != nil {
+			t.Fatalf("claiming a task: %+v", err)
+		}
+		if task == nil || task.Run == nil {
+			t.Fatalf("expected a run task, got %+v", task)
+		}
+		tg := groupOf[task.Run.Test.ID]
+		result := &models.TestResult{VerdictCode: models.VerdictCodeWrongAnswer, Verdict: "Wrong Answer", Score: 0}
+		must(dispatcher.FinishRun(task.JobID, "remote", result))
+
+		want := 2
+		if tg == stopping {
+			// The remaining tests of the group are cancelled, to be skipped when scoring.
+			want = 0
+		}
+		if got := runJobs(subOf[tg.ID]); got != want {
+			t.Errorf("group %s: expected %d run jobs left after a failure, got %d", tg.Name, want, got)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+// Package remote lets judging machines other than the main server take jobs from the queue.
+//
+// Remote workers talk to the main server over HTTP, authenticated with a shared token:
+//
+//   - POST /worker/claim claims a job, answering a Task (or 204 No Content if no jobs are ready).
+//   - GET /worker/submissions/:id/compiled, /worker/tests/:id/input and /worker/tests/:id/output
+//     fetch the compiled binary and the test data needed to run a test.
+//   - POST /worker/jobs/:id/heartbeat keeps the claim on the job alive.
+//   - POST /worker/jobs/:id/compile and /worker/jobs/:id/run push back the job's results,
+//     while POST /worker/jobs/:id/fail reports that the job could not be done.
+//
+// Only compile and run jobs are sent to remote workers, score jobs are done by the main server.
+package remote
+
+import (
+	"time"
+
+	"github.com/natsukagami/kjudge/models"
+	"github.com/natsukagami/kjudge/worker"
+)
+
+const (
+	// TokenHeader is the header carrying the shared worker token, as "Bearer <token>".
+	TokenHeader = "Authorization"
+	// NameHeader is the header carrying the worker's name.
+	NameHeader = "X-Kjudge-Worker"
+	// SandboxHeader is the header carrying the worker's sandbox implementation.
+	SandboxHeader = "X-Kjudge-Sandbox"
+
+	// PollInterval is how often an idle worker asks for a new job.
+	PollInterval = 3 * time.Second
+)
+
+// Task is a claimed job, sent to a remote worker.
+// Exactly one of Compile and Run is set, following the job's type.
+type Task struct {
+	JobID   int          `json:"job_id"`
+	Compile *CompileTask `json:"compile,omitempty"`
+	Run     *RunTask     `json:"run,omitempty"`
+}
+
+// CompileTask is everything needed to compile a submission.
+type CompileTask struct {
+	Submission *models.Submission `json:"submission"`
+	Files      []*models.File     `json:"files"`
+}
+
+// CompileResult is the result of a compile task.
+type CompileResult struct {
+	Succeeded      bool   `json:"succeeded"`
+	CompiledSource []byte `json:"compiled_source"`
+	CompilerOutput []byte `json:"compiler_output"`
+}
+
+// RunTask is everything needed to run a test, except for the compiled binary of the submission
+// and the test data, which are fetched separately.
+type RunTask struct {
+	Submission *models.Submission `json:"submission"`
+	Problem    *models.Problem    `json:"problem"`
+	TestGroup  *models.TestGroup  `json:"test_group"`
+	Test       *models.Test       `json:"test"`
+	Comparator *models.File       `json:"comparator"`
+	Checker    *models.File       `json:"checker"`
+	Stages     *models.File       `json:"stages"`
+	Interactor *models.File       `json:"interactor"`
+	// The limits are adjusted by the main server, as it knows the problem's overrides.
+	Limits models.LanguageLimits `json:"limits"`
+	// The amount of the submission's output kept on the test, in KBs.
+	KeptOutput int `json:"kept_output"`
+}
+
+// NewRunTask creates a RunTask from a RunContext with the problem files and the limits loaded.
+func NewRunTask(r *worker.RunContext) *RunTask {
+	sub := *r.Sub
+	sub.Source = nil
+	sub.CompiledSource = nil
+	test := *r.Test
+	test.Input = nil
+	test.Output = nil
+	return &RunTask{
+		Submission: &sub,
+		Problem:    r.Problem,
+		TestGroup:  r.TestGroup,
+		Test:       &test,
+		Comparator: r.Comparator,
+		Checker:    r.Checker,
+		Stages:     r.Stages,
+		Interactor: r.Interactor,
+		Limits:     r.Limits,
+		KeptOutput: r.KeptOutput,
+	}
+}
+
+// Context returns the RunContext of the task, without a database.
+func (t *RunTask) Context() *worker.RunContext {
+	return &worker.RunContext{
+		Sub:        t.Submission,
+		Problem:    t.Problem,
+		TestGroup:  t.TestGroup,
+		Test:       t.Test,
+		Comparator: t.Comparator,
+		Checker:    t.Checker,
+		Stages:     t.Stages,
+		Interactor: t.Interactor,
+		Limits:     t.Limits,
+		KeptOutput: t.KeptOutput,
+	}
+}
+
+// FailRequest reports that a job could not be done.
+type FailRequest struct {
+	Error string `json:"error"`
+}
@@ -0,0 +1,73 @@
+package remote
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/natsukagami/kjudge/models"
+)
+
+// Status is the status of a remote worker, as seen by the main server.
+type Status struct {
+	Name    string
+	Address string
+	Sandbox string
+
+	ConnectedAt time.Time
+	LastSeen    time.Time
+
+	// The job the worker is working on, 0 if it is idle.
+	JobID      int
+	JobsDone   int
+	JobsFailed int
+	LastError  string
+}
+
+// Healthy returns whether the worker has contacted the server recently.
+// Idle workers poll every PollInterval and busy workers keep extending their leases, so a healthy worker
+// is never silent for longer than a lease.
+func (s *Status) Healthy() bool {
+	return time.Since(s.LastSeen) < models.JobLeaseDuration
+}
+
+// Registry keeps track of the remote workers that contacted the server.
+type Registry struct {
+	mu      sync.Mutex
+	workers map[string]*Status
+}
+
+// NewRegistry creates an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{workers: make(map[string]*Status)}
+}
+
+// Workers returns a snapshot of all known workers, ordered by name.
+func (r *Registry) Workers() []*Status {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var list []*Status
+	for _, s := range r.workers {
+		s := *s
+		list = append(list, &s)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// Seen marks the worker as alive, and updates its status with `update`.
+func (r *Registry) Seen(name, address, sandbox string, update func(s *Status)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s, ok := r.workers[name]
+	if !ok {
+		s = &Status{Name: name, ConnectedAt: time.Now()}
+		r.workers[name] = s
+	}
+	s.Address = address
+	s.Sandbox = sandbox
+	s.LastSeen = time.Now()
+	if update != nil {
+		update(s)
+	}
+}
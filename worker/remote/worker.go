@@ -0,0 +1,107 @@
+package remote
+
+import (
+	"log"
+	"time"
+
+	"github.com/natsukagami/kjudge/models"
+	"github.com/natsukagami/kjudge/worker"
+	"github.com/natsukagami/kjudge/worker/sandbox"
+	"github.com/pkg/errors"
+)
+
+// Worker takes jobs from the main server and does them in its own sandbox.
+type Worker struct {
+	Client  *Client
+	Sandbox sandbox.Runner
+}
+
+// Start starts taking jobs. It is blocking, so might wanna "go run" it.
+func (w *Worker) Start() {
+	log.Printf("[REMOTE] Worker %s taking jobs from %s\n", w.Client.Name, w.Client.Server)
+	for {
+		task, err := w.Client.Claim()
+		if err != nil {
+			log.Printf("[REMOTE] Fetching job failed: %+v\n", err)
+		}
+		if task == nil {
+			time.Sleep(PollInterval)
+			continue
+		}
+		stopLease := w.keepLease(task.JobID)
+		err = w.handle(task)
+		stopLease()
+		if errors.Is(err, ErrJobLost) {
+			log.Printf("[REMOTE] Job %d was taken away from this worker, dropping it\n", task.JobID)
+		} else if err != nil {
+			log.Printf("[REMOTE] Handling job %d failed: %+v\n", task.JobID, err)
+			if err := w.Client.Fail(task.JobID, err); err != nil {
+				log.Printf("[REMOTE] Reporting job failure failed: %+v\n", err)
+			}
+		}
+	}
+}
+
+// Does the task and pushes back its result.
+func (w *Worker) handle(task *Task) error {
+	switch {
+	case task.Compile != nil:
+		sub := task.Compile.Submission
+		succeeded, err := worker.CompileSubmission(w.Sandbox, sub, task.Compile.Files)
+		if err != nil {
+			return err
+		}
+		return w.Client.FinishCompile(task.JobID, &CompileResult{
+			Succeeded:      succeeded,
+			CompiledSource: sub.CompiledSource,
+			CompilerOutput: sub.CompilerOutput,
+		})
+	case task.Run != nil:
+		r := task.Run.Context()
+		source, err := w.Client.CompiledSource(r.Sub.ID)
+		if err != nil {
+			return err
+		}
+		r.Sub.CompiledSource = source
+		if r.Test.Input, r.Test.Output, err = w.Client.TestData(r.Test.ID); err != nil {
+			return err
+		}
+		result, err := worker.RunTest(w.Sandbox, r)
+		if err != nil {
+			return err
+		}
+		return w.Client.FinishRun(task.JobID, result)
+	default:
+		return errors.Errorf("job %d has nothing to do", task.JobID)
+	}
+}
+
+// Keeps extending the claim on the job until the returned function is called.
+func (w *Worker) keepLease(jobID int) func() {
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(models.JobLeaseDuration / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				err := w.Client.Heartbeat(jobID)
+				if errors.Is(err, ErrJobLost) {
+					// The server throws the results away, so stop keeping the claim.
+					log.Printf("[REMOTE] Lost the claim on job %d\n", jobID)
+					return
+				} else if err != nil {
+					log.Printf("[REMOTE] Extending job lease failed: %+v\n", err)
+				}
+			}
+		}
+	}()
+	return func() {
+		close(stop)
+		<-done
+	}
+}
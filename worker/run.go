@@ -2,14 +2,17 @@ package worker
 
 import (
 	"database/sql"
+	"fmt"
 	"log"
 	"math"
+	"os"
 	"strconv"
 	"strings"
 	"time"
 
-	"github.com/jmoiron/sqlx"
+	"github.com/natsukagami/kjudge/db"
 	"github.com/natsukagami/kjudge/models"
+	"github.com/natsukagami/kjudge/tests"
 	"github.com/natsukagami/kjudge/worker/sandbox"
 	"github.com/pkg/errors"
 )
@@ -17,45 +20,106 @@ import (
 // The filename of the "compare" binary.
 const CompareFilename = "compare"
 
+// The filename of the "checker" binary, a testlib checker.
+// Checkers are run with the arguments "input output answer" (the test's input, the submission's output and
+// the test's expected output), and give their verdict with testlib's exit codes: 0 (OK), 1 (Wrong Answer),
+// 2 (Presentation Error), 3 (FAIL, the checker itself failed) and 7 (partial score, printed as "points <score>"
+// on the standard error, with the score between 0 and 1).
+// When a problem has both a checker and a "compare" binary, the checker is used.
+const CheckerFilename = "checker"
+
+// The filename of the "interactor" binary.
+// Problems with an interactor are interactive: the submission's standard input and output are connected to
+// the interactor, which is run with the arguments "input expected" (the test's input and output files).
+// When the interaction is done, the interactor writes the score (between 0 and 1) on the first line
+// of its standard error, followed by the verdict message.
+const InteractorFilename = "interactor"
+
+// DefaultKeptOutput is the default amount of the submission's output and standard error kept on each test, in KBs.
+const DefaultKeptOutput = 8
+
 // RunContext is the context needed to run a test.
 type RunContext struct {
-	DB        *sqlx.Tx
+	DB        db.DBContext
 	Sub       *models.Submission
 	Problem   *models.Problem
 	TestGroup *models.TestGroup
 	Test      *models.Test
+
+	// The problem files needed to run the test, loaded by LoadFiles.
+	// Comparator is the "compare" binary, nil if the outputs are compared with diff.
+	Comparator *models.File
+	// Checker is the testlib "checker" binary, nil if there is none.
+	Checker *models.File
+	// Stages is the ".stages" file of chained problems, nil otherwise.
+	Stages *models.File
+	// Interactor is the "interactor" binary of interactive problems, nil otherwise.
+	Interactor *models.File
+
+	// The adjustment of the limits for the submission's language, loaded by LoadLimits.
+	Limits models.LanguageLimits
+
+	// The amount of the submission's output and standard error kept in the test result, in KBs.
+	KeptOutput int
+}
+
+// LoadFiles loads the problem files needed to run the test from the database.
+func (r *RunContext) LoadFiles() error {
+	var err error
+	if r.Comparator, err = r.optionalFile(CompareFilename); err != nil {
+		return err
+	}
+	if r.Checker, err = r.optionalFile(CheckerFilename); err != nil {
+		return err
+	}
+	if r.Stages, err = r.optionalFile(".stages"); err != nil {
+		return err
+	}
+	if r.Interactor, err = r.optionalFile(InteractorFilename); err != nil {
+		return err
+	}
+	return nil
+}
+
+// LoadLimits loads the adjustment of the limits for the submission's language from the database.
+func (r *RunContext) LoadLimits() error {
+	var err error
+	r.Limits, err = models.GetLanguageLimits(r.DB, r.Problem.ID, r.Sub.Language)
+	return err
+}
+
+// Returns the problem file with the given name, or nil if there is none.
+func (r *RunContext) optionalFile(name string) (*models.File, error) {
+	file, err := models.GetFileWithName(r.DB, r.Problem.ID, name)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return file, err
 }
 
-// TimeLimit returns the time limit of the context, in time.Duration.
+// TimeLimit returns the time limit of the context for the submission's language, in time.Duration.
 func (r *RunContext) TimeLimit() time.Duration {
 	if r.TestGroup.TimeLimit.Valid {
-		return time.Duration(r.TestGroup.TimeLimit.Int64) * time.Millisecond
+		return r.Limits.TimeLimit(time.Duration(r.TestGroup.TimeLimit.Int64) * time.Millisecond)
 	}
-	return time.Duration(r.Problem.TimeLimit) * time.Millisecond
+	return r.Limits.TimeLimit(time.Duration(r.Problem.TimeLimit) * time.Millisecond)
 }
 
-// MemoryLimit returns the memory limit of the context, in Kilobytes.
+// MemoryLimit returns the memory limit of the context for the submission's language, in Kilobytes.
 func (r *RunContext) MemoryLimit() int {
 	if r.TestGroup.MemoryLimit.Valid {
-		return int(r.TestGroup.MemoryLimit.Int64)
+		return r.Limits.MemoryLimit(int(r.TestGroup.MemoryLimit.Int64))
 	}
-	return r.Problem.MemoryLimit
+	return r.Limits.MemoryLimit(r.Problem.MemoryLimit)
 }
 
 // RunnCommand returns the run command (command, args list) for the language.
 func RunCommand(l models.Language) (string, []string, error) {
-	switch l {
-	case models.LanguageJava:
-		return "/usr/bin/java", []string{"-Donline_judge=true", "-Dkjudge=true", "-Smx512M", "-Xss64M", "-cp", "code", "Main"}, nil
-	case models.LanguagePy2:
-		return "/usr/bin/python2", []string{"-S", "code"}, nil
-	case models.LanguagePy3:
-		return "/usr/bin/python3", []string{"-S", "code"}, nil
-	case models.LanguageCpp, models.LanguageGo, models.LanguageRust, models.LanguagePas:
-		return "code", nil, nil
-	default:
+	spec := l.Spec()
+	if spec == nil {
 		return "", nil, errors.New("unknown language")
 	}
+	return spec.Run[0], spec.Run[1:], nil
 }
 
 // CompiledSource returns the CompiledSource. Returns false when the submission hasn't been compiled.
@@ -85,11 +149,39 @@ func (r *RunContext) RunInput(source []byte) (*sandbox.Input, error) {
 	}, nil
 }
 
+// CompareMode is the way the submission's output is checked.
+type CompareMode int
+
+const (
+	// CompareDiff compares the outputs with diff.
+	CompareDiff CompareMode = iota
+	// CompareComparator runs the problem's "compare" binary.
+	CompareComparator
+	// CompareChecker runs the problem's testlib "checker" binary.
+	CompareChecker
+	// CompareBuiltin compares the outputs inside the worker, following the problem's comparison mode.
+	CompareBuiltin
+)
+
 // CompareInput creates a SandboxInput for running the comparator.
-// Also returns whether we have diff-based or comparator-based input.
-func (r *RunContext) CompareInput(submissionOutput []byte) (input *sandbox.Input, useComparator bool, err error) {
-	file, err := models.GetFileWithName(r.DB, r.Problem.ID, "compare")
-	if errors.Is(err, sql.ErrNoRows) {
+// Also returns how the output is compared. The input is nil for the built-in comparators.
+func (r *RunContext) CompareInput(submissionOutput []byte) (input *sandbox.Input, mode CompareMode, err error) {
+	if r.Checker != nil {
+		// Use the testlib checker, with testlib's argument order.
+		return &sandbox.Input{
+			Command:     "code",
+			Args:        []string{"input", "output", "answer"},
+			Files:       map[string][]byte{"input": r.Test.Input, "answer": r.Test.Output, "output": submissionOutput},
+			TimeLimit:   20 * time.Second,
+			MemoryLimit: (1 << 20), // 1 GB
+
+			CompiledSource: r.Checker.Content,
+		}, CompareChecker, nil
+	}
+	if r.Comparator == nil && r.Problem.ComparisonMode != models.ComparisonModeDiff && r.Problem.ComparisonMode != "" {
+		return nil, CompareBuiltin, nil
+	}
+	if r.Comparator == nil {
 		// Use a simple diff
 		return &sandbox.Input{
 			Command:     "/usr/bin/diff",
@@ -97,10 +189,7 @@ func (r *RunContext) CompareInput(submissionOutput []byte) (input *sandbox.Input
 			Files:       map[string][]byte{"output": submissionOutput, "expected": r.Test.Output},
 			TimeLimit:   time.Second,
 			MemoryLimit: 262144, // 256MBs
-		}, false, nil
-	}
-	if err != nil {
-		return nil, false, err
+		}, CompareDiff, nil
 	}
 	// Use the given comparator.
 	return &sandbox.Input{
@@ -110,8 +199,23 @@ func (r *RunContext) CompareInput(submissionOutput []byte) (input *sandbox.Input
 		TimeLimit:   20 * time.Second,
 		MemoryLimit: (1 << 20), // 1 GB
 
-		CompiledSource: file.Content,
-	}, true, nil
+		CompiledSource: r.Comparator.Content,
+	}, CompareComparator, nil
+}
+
+// InteractorInput creates a SandboxInput for running the interactor.
+// Its standard input and output are left to be connected to the submission.
+func (r *RunContext) InteractorInput() *sandbox.Input {
+	return &sandbox.Input{
+		Command: "code",
+		Args:    []string{"input", "expected"},
+		Files:   map[string][]byte{"input": r.Test.Input, "expected": r.Test.Output},
+		// The interactor mostly waits for the submission, give it plenty of time.
+		TimeLimit:   2*r.TimeLimit() + 10*time.Second,
+		MemoryLimit: (1 << 20), // 1 GB
+
+		CompiledSource: r.Interactor.Content,
+	}
 }
 
 func RunSingleCommand(s sandbox.Runner, r *RunContext, source []byte) (output *sandbox.Output, err error) {
@@ -120,6 +224,7 @@ func RunSingleCommand(s sandbox.Runner, r *RunContext, source []byte) (output *s
 	if err != nil {
 		return nil, err
 	}
+	r.setFileIO(input, r.Test.Input)
 	output, err = s.Run(input)
 	if err != nil {
 		return nil, errors.WithStack(err)
@@ -127,6 +232,29 @@ func RunSingleCommand(s sandbox.Runner, r *RunContext, source []byte) (output *s
 	return output, nil
 }
 
+// Gives the input to the command through the problem's input file, or the standard input if there is none,
+// and asks for the problem's output file to be collected from the box (see SubmissionOutput).
+func (r *RunContext) setFileIO(input *sandbox.Input, content []byte) {
+	input.Input = content
+	if r.Problem.FileInput() {
+		input.Files = map[string][]byte{r.Problem.InputFile: content}
+		input.Input = nil
+	}
+	if r.Problem.FileOutput() {
+		input.OutputFiles = []string{r.Problem.OutputFile}
+	}
+}
+
+// SubmissionOutput returns the output of a submission run by RunSingleCommand or RunMultipleCommands:
+// the problem's output file, or the standard output if there is none. Returns false if the output file was not written.
+func (r *RunContext) SubmissionOutput(output *sandbox.Output) ([]byte, bool) {
+	if !r.Problem.FileOutput() {
+		return output.Stdout, true
+	}
+	content, ok := output.Files[r.Problem.OutputFile]
+	return content, ok
+}
+
 func RunMultipleCommands(s sandbox.Runner, r *RunContext, source []byte, stages []string) (output *sandbox.Output, err error) {
 	command, args, err := RunCommand(r.Sub.Language)
 	if err != nil {
@@ -149,8 +277,8 @@ func RunMultipleCommands(s sandbox.Runner, r *RunContext, source []byte, stages
 			MemoryLimit: r.MemoryLimit(),
 
 			CompiledSource: source,
-			Input:          input,
 		}
+		r.setFileIO(sandboxInput, input)
 
 		output, err = s.Run(sandboxInput)
 		if err != nil {
@@ -160,43 +288,106 @@ func RunMultipleCommands(s sandbox.Runner, r *RunContext, source []byte, stages
 		if !output.Success {
 			break
 		}
-		// Next input in the chain will be the standard output of the previous command run
-		input = output.Stdout
+		// Next input in the chain will be the output of the previous command run.
+		// Stop if it was not written, SubmissionOutput reports it.
+		var ok bool
+		if input, ok = r.SubmissionOutput(output); !ok {
+			break
+		}
 	}
 	return output, nil
 }
 
-// Run runs a RunContext.
-func Run(s sandbox.Runner, r *RunContext) error {
-	compiled, source := r.CompiledSource()
-	if !compiled {
-		// Add a compilation job and re-add ourselves.
-		log.Printf("[WORKER] Submission %v not compiled, creating Compile job.\n", r.Sub.ID)
-		return models.BatchInsertJobs(r.DB, models.NewJobCompile(r.Sub.ID), models.NewJobRun(r.Sub.ID, r.Test.ID))
+// InteractiveOutput is the output of running a submission together with the interactor.
+type InteractiveOutput struct {
+	Submission *sandbox.Output
+	Interactor *sandbox.Output
+	// Whether the interactor exited before the submission.
+	InteractorFirst bool
+}
+
+// RunInteractive runs the submission together with the interactor, in its helper box.
+// The submission's standard output is piped into the interactor's standard input, and the other way around.
+// Each side's running time is measured by its own box.
+func RunInteractive(s sandbox.Runner, r *RunContext, source []byte) (*InteractiveOutput, error) {
+	input, err := r.RunInput(source)
+	if err != nil {
+		return nil, err
 	}
-	if source == nil {
-		log.Printf("[WORKER] Not running a submission that failed to compile.\n")
-		return nil
+	fromSubmission, toInteractor, err := os.Pipe()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	fromInteractor, toSubmission, err := os.Pipe()
+	if err != nil {
+		fromSubmission.Close()
+		toInteractor.Close()
+		return nil, errors.WithStack(err)
 	}
+	input.Input = nil
+	input.Stdin, input.Stdout = fromInteractor, toInteractor
+	interactor := r.InteractorInput()
+	interactor.Stdin, interactor.Stdout = fromSubmission, toSubmission
 
+	// Whenever one side exits, close its ends of the pipes, so that the other side does not wait on it forever.
+	var (
+		out           InteractiveOutput
+		interactorErr error
+		interactorEnd time.Time
+		done          = make(chan struct{})
+	)
+	go func() {
+		defer close(done)
+		out.Interactor, interactorErr = s.Helper().Run(interactor)
+		interactorEnd = time.Now()
+		fromSubmission.Close()
+		toSubmission.Close()
+	}()
+	out.Submission, err = s.Run(input)
+	submissionEnd := time.Now()
+	fromInteractor.Close()
+	toInteractor.Close()
+	<-done
+
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if interactorErr != nil {
+		return nil, errors.Wrap(interactorErr, "running interactor")
+	}
+	out.InteractorFirst = interactorEnd.Before(submissionEnd)
+	return &out, nil
+}
+
+// RunTest runs the compiled submission on the test and returns the result.
+// The problem files and the limits must have been loaded. It does not touch the database, so that remote workers can also do it.
+func RunTest(s sandbox.Runner, r *RunContext) (*models.TestResult, error) {
+	source := r.Sub.CompiledSource
 	log.Printf("[WORKER] Running submission %v on [test `%v`, group `%v`]\n", r.Sub.ID, r.Test.Name, r.TestGroup.Name)
 
-	var output *sandbox.Output
-	file, err := models.GetFileWithName(r.DB, r.Problem.ID, ".stages")
-	if errors.Is(err, sql.ErrNoRows) {
+	if r.Sub.OutputOnly() {
+		return runOutputOnlyTest(s, r, source)
+	}
+	if r.Interactor != nil {
+		return runInteractiveTest(s, r, source)
+	}
+
+	var (
+		output *sandbox.Output
+		err    error
+	)
+	if r.Stages == nil {
 		// Problem type is not Chained Type, run a single command
 		output, err = RunSingleCommand(s, r, source)
 		if err != nil {
-			return err
+			return nil, err
 		}
-	} else if err != nil {
-		return err
 	} else {
 		// Problem Type is Chained Type, we need to run mutiple commands with arguments from .stages (file)
-		stages := strings.Split(string(file.Content), "\n")
+		stages := strings.Split(string(r.Stages.Content), "\n")
 		output, err = RunMultipleCommands(s, r, source, stages)
 		if err != nil {
-			return err
+			return nil, err
 		}
 	}
 
@@ -207,46 +398,161 @@ func Run(s sandbox.Runner, r *RunContext) error {
 			result.Verdict = output.ErrorMessage
 		}
 		// If running the source did not succeed, we stop here and be happy with the test result.
-		return result.Write(r.DB)
+		return result, nil
 	}
 
-	// Attempt to run the comparator
-	input, useComparator, err := r.CompareInput(output.Stdout)
-	if err != nil {
-		return err
+	submissionOutput, ok := r.SubmissionOutput(output)
+	if !ok {
+		result.Score = 0
+		result.Verdict = fmt.Sprintf("Output file `%s` not found", r.Problem.OutputFile)
+		result.VerdictCode = models.VerdictCodeWrongAnswer
+		return result, nil
 	}
-	output, err = s.Run(input)
-	if err != nil {
-		return err
+	if r.Problem.FileOutput() {
+		result.Output = keptOutput(submissionOutput, r.KeptOutput)
 	}
-	if err := parseComparatorOutput(output, result, useComparator); err != nil {
-		return err
+	if err := compareOutput(s, r, submissionOutput, result); err != nil {
+		return nil, err
 	}
 
 	log.Printf("[WORKER] Done running submission %v on [test `%v`, group `%v`]: %.1f (t = %v, m = %v)\n",
 		r.Sub.ID, r.Test.Name, r.TestGroup.Name, result.Score, result.RunningTime, result.MemoryUsed)
 
-	return result.Write(r.DB)
+	return result, nil
 }
 
-// Parse the comparator's output and reflect it into `result`.
-func parseComparatorOutput(s *sandbox.Output, result *models.TestResult, useComparator bool) error {
-	if useComparator {
-		// Paste the comparator's output to result
-		result.Verdict = strings.TrimSpace(string(s.Stderr))
-		if result.Verdict == "" {
-			result.Verdict = "Compare returns no output."
-		}
-		score, err := strconv.ParseFloat(strings.TrimSpace(string(s.Stdout)), 64)
+// Compares the submission's output on the test with the expected output, and reflects the comparison into `result`.
+func compareOutput(s sandbox.Runner, r *RunContext, submissionOutput []byte, result *models.TestResult) error {
+	input, mode, err := r.CompareInput(submissionOutput)
+	if err != nil {
+		return err
+	}
+	if mode == CompareBuiltin {
+		compareBuiltin(r.Problem, submissionOutput, r.Test.Output, result)
+		result.CheckerMessage = result.Verdict
+	} else {
+		output, err := s.Run(input)
 		if err != nil {
-			return errors.WithStack(err)
+			return err
 		}
-		if math.IsInf(score, 0) || math.IsNaN(score) || score < 0 || score > 1 {
-			return errors.Errorf("invalid comparator score %f", score)
+		if err := parseComparatorOutput(output, result, mode); err != nil {
+			judgeError(result, err)
+			return nil
 		}
-		result.Score = score
-	} else {
+	}
+	result.VerdictCode = models.VerdictCodeOfScore(result.Score)
+	return nil
+}
+
+// Marks the result as a Judge Error, caused by a misbehaving checker, comparator or interactor.
+// Those fail the same way on every attempt, so the error is recorded right away instead of failing the job,
+// which is kept for errors of the judge's infrastructure.
+func judgeError(result *models.TestResult, err error) {
+	result.Score = 0
+	result.VerdictCode = models.VerdictCodeJudgeError
+	result.Verdict = err.Error()
+}
+
+// Checks the output of an output-only submission on the test, taken from its archive of outputs.
+func runOutputOnlyTest(s sandbox.Runner, r *RunContext, archive []byte) (*models.TestResult, error) {
+	output, ok, err := tests.ReadOutput(archive, r.Test.Name)
+	if err != nil {
+		return nil, err
+	}
+	result := &models.TestResult{
+		SubmissionID: r.Sub.ID,
+		TestID:       r.Test.ID,
+		Output:       keptOutput(output, r.KeptOutput),
+	}
+	if !ok {
+		result.Verdict = "No output file for the test"
+		result.VerdictCode = models.VerdictCodeWrongAnswer
+		return result, nil
+	}
+	result.Score = 1
+	if err := compareOutput(s, r, output, result); err != nil {
+		return nil, err
+	}
+
+	log.Printf("[WORKER] Done checking the output of submission %v on [test `%v`, group `%v`]: %.1f\n",
+		r.Sub.ID, r.Test.Name, r.TestGroup.Name, result.Score)
+
+	return result, nil
+}
+
+// Runs an interactive test, see InteractorFilename.
+func runInteractiveTest(s sandbox.Runner, r *RunContext, source []byte) (*models.TestResult, error) {
+	out, err := RunInteractive(s, r, source)
+	if err != nil {
+		return nil, err
+	}
+	result := parseSandboxOutput(out.Submission, r)
+	failed := func() (*models.TestResult, error) {
+		result.VerdictCode = failureVerdictCode(out.Submission, r)
+		result.Verdict = "Runtime Error"
+		if out.Submission.ErrorMessage != "" {
+			result.Verdict = out.Submission.ErrorMessage
+		}
+		return result, nil
+	}
+	if !out.Interactor.Success {
+		if !out.Submission.Success {
+			// The interactor most likely died because the submission went away.
+			return failed()
+		}
+		judgeError(result, errors.Errorf("interactor failed (%s): %s", out.Interactor.ErrorMessage, out.Interactor.Stderr))
+		return result, nil
+	}
+	result.CheckerMessage = string(out.Interactor.Stderr)
+	score, message, _ := strings.Cut(string(out.Interactor.Stderr), "\n")
+	if err := parseScoreMessage(result, []byte(score), []byte(message), "Interactor returns no output."); err != nil {
+		judgeError(result, errors.Wrap(err, "invalid interactor output"))
+		return result, nil
+	}
+	result.VerdictCode = models.VerdictCodeOfScore(result.Score)
+	// When the interactor gives up first (e.g. on a wrong answer), the submission usually fails because of it,
+	// so the interactor's verdict is kept. Otherwise the submission's failure comes first.
+	if !out.Submission.Success && !(out.InteractorFirst && result.Score < 1) {
+		result.Score = 0
+		return failed()
+	}
+
+	log.Printf("[WORKER] Done running submission %v on [test `%v`, group `%v`]: %.1f (t = %v, interactor t = %v, m = %v)\n",
+		r.Sub.ID, r.Test.Name, r.TestGroup.Name, result.Score, result.RunningTime, out.Interactor.RunningTime, result.MemoryUsed)
+
+	return result, nil
+}
+
+// Parse a score (between 0 and 1) and a verdict message, as written by comparators and interactors,
+// into `result`.
+func parseScoreMessage(result *models.TestResult, score, message []byte, noMessage string) error {
+	result.Verdict = strings.TrimSpace(string(message))
+	if result.Verdict == "" {
+		result.Verdict = noMessage
+	}
+	value, err := strconv.ParseFloat(strings.TrimSpace(string(score)), 64)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if math.IsInf(value, 0) || math.IsNaN(value) || value < 0 || value > 1 {
+		return errors.Errorf("invalid score %f", value)
+	}
+	result.Score = value
+	return nil
+}
+
+// Parse the comparator's output and reflect it into `result`.
+func parseComparatorOutput(s *sandbox.Output, result *models.TestResult, mode CompareMode) error {
+	result.CheckerMessage = string(s.Stderr)
+	switch mode {
+	case CompareChecker:
+		return parseCheckerOutput(s, result)
+	case CompareComparator:
+		// Paste the comparator's output to result
+		return errors.Wrap(parseScoreMessage(result, s.Stdout, s.Stderr, "Compare returns no output."), "invalid comparator output")
+	default:
 		// Cute message from diff
+		result.CheckerMessage = string(s.Stdout)
 		result.Verdict = strings.TrimSpace(string(s.Stdout))
 		if result.Verdict == "" {
 			result.Verdict = "Diff failed"
@@ -258,6 +564,57 @@ func parseComparatorOutput(s *sandbox.Output, result *models.TestResult, useComp
 	return nil
 }
 
+// The exit codes of testlib checkers.
+const (
+	testlibOK            = 0
+	testlibWrongAnswer   = 1
+	testlibPresentation  = 2
+	testlibFail          = 3
+	testlibDirt          = 4
+	testlibPoints        = 7
+	testlibUnexpectedEOF = 8
+)
+
+// Parse the output of a testlib checker, see CheckerFilename.
+// A failing checker is an error of the judge, not of the submission.
+func parseCheckerOutput(s *sandbox.Output, result *models.TestResult) error {
+	message := strings.TrimSpace(string(s.Stderr))
+	verdict := func(score float64, fallback string) error {
+		result.Score = score
+		result.Verdict = message
+		if result.Verdict == "" {
+			result.Verdict = fallback
+		}
+		return nil
+	}
+	if !s.Success && s.ExitCode == 0 {
+		return errors.Errorf("checker failed (%s): %s", s.ErrorMessage, message)
+	}
+	switch s.ExitCode {
+	case testlibOK:
+		return verdict(1, "Accepted")
+	case testlibWrongAnswer:
+		return verdict(0, "Wrong Answer")
+	case testlibPresentation, testlibDirt:
+		return verdict(0, "Presentation Error")
+	case testlibUnexpectedEOF:
+		return verdict(0, "Unexpected EOF")
+	case testlibPoints:
+		// Checkers write the points themselves, so they are clamped into [0, 1] instead of trusted.
+		points, rest, _ := strings.Cut(strings.TrimPrefix(message, "points "), " ")
+		score, err := strconv.ParseFloat(points, 64)
+		if err != nil || math.IsNaN(score) {
+			return errors.Errorf("checker gave invalid points: %s", message)
+		}
+		message = strings.TrimSpace(rest)
+		return verdict(math.Max(0, math.Min(1, score)), "Partially Correct")
+	case testlibFail:
+		return errors.Errorf("checker failed: %s", message)
+	default:
+		return errors.Errorf("checker exited with unknown code %d: %s", s.ExitCode, message)
+	}
+}
+
 // Parse the sandbox output into a TestResult.
 func parseSandboxOutput(s *sandbox.Output, r *RunContext) *models.TestResult {
 	score := 1.0
@@ -271,5 +628,40 @@ func parseSandboxOutput(s *sandbox.Output, r *RunContext) *models.TestResult {
 		SubmissionID: r.Sub.ID,
 		TestID:       r.Test.ID,
 		Verdict:      s.ErrorMessage,
+		VerdictCode:  failureVerdictCode(s, r),
+		Output:       keptOutput(s.Stdout, r.KeptOutput),
+		Stderr:       keptOutput(s.Stderr, r.KeptOutput),
+	}
+}
+
+// Returns the beginning of the output to be kept, at most `kbs` KBs of it.
+func keptOutput(output []byte, kbs int) []byte {
+	if limit := kbs * 1024; len(output) > limit {
+		output = output[:limit]
+	}
+	if len(output) == 0 {
+		return nil
+	}
+	return append([]byte(nil), output...)
+}
+
+// SIGXFSZ on Linux, which kills commands writing files over the output limit.
+const signalFileSizeExceeded = 25
+
+// Returns the verdict of a submission that did not run successfully, or Accepted if it did.
+func failureVerdictCode(s *sandbox.Output, r *RunContext) models.VerdictCode {
+	switch {
+	case s.Success:
+		return models.VerdictCodeAccepted
+	case s.Status == sandbox.StatusInternalError:
+		return models.VerdictCodeJudgeError
+	case s.OOMKilled || s.MemoryUsed > r.MemoryLimit():
+		return models.VerdictCodeMemoryLimitExceeded
+	case s.Status == sandbox.StatusTimedOut:
+		return models.VerdictCodeTimeLimitExceeded
+	case s.Status == sandbox.StatusSignaled && s.Signal == signalFileSizeExceeded:
+		return models.VerdictCodeOutputLimitExceeded
+	default:
+		return models.VerdictCodeRuntimeError
 	}
 }
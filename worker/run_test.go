@@ -0,0 +1,111 @@
+package worker
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/natsukagami/kjudge/models"
+	"github.com/natsukagami/kjudge/worker/sandbox"
+)
+
+func TestParseCheckerOutput(t *testing.T) {
+	tests := []struct {
+		name     string
+		output   sandbox.Output
+		score    float64
+		verdict  string
+		judgeErr bool
+	}{
+		{"ok", sandbox.Output{Success: true, Stderr: []byte("ok 3 numbers\n")}, 1, "ok 3 numbers", false},
+		{"ok without message", sandbox.Output{Success: true}, 1, "Accepted", false},
+		{"wrong answer", sandbox.Output{ExitCode: 1, Stderr: []byte("wrong answer 2nd numbers differ")}, 0, "wrong answer 2nd numbers differ", false},
+		{"wrong answer without message", sandbox.Output{ExitCode: 1}, 0, "Wrong Answer", false},
+		{"presentation error", sandbox.Output{ExitCode: 2}, 0, "Presentation Error", false},
+		{"dirt", sandbox.Output{ExitCode: 4}, 0, "Presentation Error", false},
+		{"unexpected eof", sandbox.Output{ExitCode: 8}, 0, "Unexpected EOF", false},
+		{"points", sandbox.Output{ExitCode: 7, Stderr: []byte("points 0.25 almost")}, 0.25, "almost", false},
+		{"points without message", sandbox.Output{ExitCode: 7, Stderr: []byte("points 0.5")}, 0.5, "Partially Correct", false},
+		{"points above one", sandbox.Output{ExitCode: 7, Stderr: []byte("points 3")}, 1, "Partially Correct", false},
+		{"points below zero", sandbox.Output{ExitCode: 7, Stderr: []byte("points -1")}, 0, "Partially Correct", false},
+		{"invalid points", sandbox.Output{ExitCode: 7, Stderr: []byte("points many")}, 0, "", true},
+		{"nan points", sandbox.Output{ExitCode: 7, Stderr: []byte("points nan")}, 0, "", true},
+		{"fail", sandbox.Output{ExitCode: 3, Stderr: []byte("FAIL answer is wrong")}, 0, "", true},
+		{"unknown code", sandbox.Output{ExitCode: 42}, 0, "", true},
+		{"killed", sandbox.Output{Status: sandbox.StatusTimedOut, ErrorMessage: "Time limit exceeded"}, 0, "", true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result := &models.TestResult{Score: -1}
+			err := parseCheckerOutput(&test.output, result)
+			if test.judgeErr {
+				if err == nil {
+					t.Errorf("expected a judge error, got score %v, verdict %q", result.Score, result.Verdict)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if result.Score != test.score || result.Verdict != test.verdict {
+				t.Errorf("expected score %v, verdict %q; got score %v, verdict %q", test.score, test.verdict, result.Score, result.Verdict)
+			}
+		})
+	}
+}
+
+// Runs every command with the same output.
+type fakeRunner struct {
+	sandbox.Runner
+	output *sandbox.Output
+	err    error
+}
+
+func (f *fakeRunner) Run(*sandbox.Input) (*sandbox.Output, error) { return f.output, f.err }
+
+func TestCompareOutputJudgeError(t *testing.T) {
+	tests := []struct {
+		name     string
+		r        *RunContext
+		output   *sandbox.Output
+		verdict  models.VerdictCode
+		message  string
+		judgeErr bool
+	}{
+		{"checker accepts", &RunContext{Checker: &models.File{}}, &sandbox.Output{Success: true}, models.VerdictCodeAccepted, "Accepted", false},
+		{"checker fails", &RunContext{Checker: &models.File{}}, &sandbox.Output{ExitCode: 3, Stderr: []byte("FAIL no answer")},
+			models.VerdictCodeJudgeError, "checker failed: FAIL no answer", false},
+		{"checker crashes", &RunContext{Checker: &models.File{}}, &sandbox.Output{ExitCode: 42},
+			models.VerdictCodeJudgeError, "checker exited with unknown code 42: ", false},
+		{"comparator writes no score", &RunContext{Comparator: &models.File{}}, &sandbox.Output{Success: true, Stdout: []byte("yes")},
+			models.VerdictCodeJudgeError, `invalid comparator output: strconv.ParseFloat: parsing "yes": invalid syntax`, false},
+		{"sandbox fails", &RunContext{Checker: &models.File{}}, nil, "", "", true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.r.Problem = &models.Problem{}
+			test.r.Test = &models.Test{}
+			s := &fakeRunner{output: test.output}
+			if test.output == nil {
+				s.err = errors.New("isolate --init failed")
+			}
+			result := &models.TestResult{Score: 1}
+			err := compareOutput(s, test.r, nil, result)
+			if test.judgeErr {
+				// Errors of the judge's infrastructure fail the job, to be retried.
+				if err == nil {
+					t.Errorf("expected an error, got verdict %s", result.VerdictCode)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if result.VerdictCode != test.verdict || result.Verdict != test.message {
+				t.Errorf("expected %s %q, got %s %q", test.verdict, test.message, result.VerdictCode, result.Verdict)
+			}
+			if test.verdict == models.VerdictCodeJudgeError && result.Score != 0 {
+				t.Errorf("expected no score on a Judge Error, got %v", result.Score)
+			}
+		})
+	}
+}
@@ -14,6 +14,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
@@ -43,6 +44,7 @@ func init() {
 // Runner implements worker.Runner.
 type Runner struct {
 	version  int // 1 or 2
+	box      int // The isolate box ID
 	cmd      *exec.Cmd
 	settings sandbox.Settings
 	private  struct{} // Makes the sandbox not simply constructible
@@ -137,11 +139,27 @@ func (s *Runner) Settings() *sandbox.Settings {
 	return &s.settings
 }
 
+// Box implements Runner.Box.
+func (s *Runner) Box(id int) sandbox.Runner {
+	runner := *s
+	runner.box = id
+	return &runner
+}
+
+// Helper implements Runner.Helper.
+func (s *Runner) Helper() sandbox.Runner {
+	return s.Box(s.box + sandbox.HelperBoxOffset)
+}
+
+func (s *Runner) boxArg() string {
+	return fmt.Sprintf("--box-id=%d", s.box)
+}
+
 // Run implements Runner.Run.
 func (s *Runner) Run(input *sandbox.Input) (*sandbox.Output, error) {
 	// Init the sandbox
 	defer s.cleanup()
-	dirBytes, err := exec.Command(s.isolateCommand(), "--init", "--cg").Output()
+	dirBytes, err := exec.Command(s.isolateCommand(), "--init", "--cg", s.boxArg()).Output()
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
@@ -155,11 +173,14 @@ func (s *Runner) Run(input *sandbox.Input) (*sandbox.Output, error) {
 	// Prepare a meta file.
 	tmp := os.TempDir()
 
-	metaFile := filepath.Join(tmp, "meta.txt")
-	cmd := buildCmd(dir, metaFile, input)
+	metaFile := filepath.Join(tmp, fmt.Sprintf("meta-%d.txt", s.box))
+	cmd := buildCmd(dir, metaFile, s.boxArg(), input)
 
 	var stdout, stderr bytes.Buffer
 	cmd.Stdout = &stdout
+	if input.Stdout != nil {
+		cmd.Stdout = input.Stdout
+	}
 	cmd.Stderr = &stderr
 
 	if err := cmd.Run(); err != nil {
@@ -176,6 +197,9 @@ func (s *Runner) Run(input *sandbox.Input) (*sandbox.Output, error) {
 	if err := parseMetaFile(metaFile, output); err != nil {
 		return nil, err
 	}
+	if output.Files, err = input.ReadFiles(dir); err != nil {
+		return nil, err
+	}
 
 	return output, nil
 }
@@ -191,34 +215,57 @@ func parseMetaFile(path string, output *sandbox.Output) error {
 	} else {
 		output.Success = true
 	}
+	if _, ok := meta.Fields["status"]; ok {
+		output.Status = sandbox.Status(meta.String("status"))
+	}
+	if _, ok := meta.Fields["exitcode"]; ok {
+		output.ExitCode = meta.Int("exitcode")
+	}
+	if _, ok := meta.Fields["exitsig"]; ok {
+		output.Signal = meta.Int("exitsig")
+	}
+	if _, ok := meta.Fields["cg-oom-killed"]; ok {
+		output.OOMKilled = true
+	}
 	output.MemoryUsed = meta.Int("cg-mem")
 	output.RunningTime = time.Duration(float64(time.Second) * meta.Float64("time"))
 	return meta.Error()
 }
 
 // Build the command for isolate --run.
-func buildCmd(dir, metaFile string, input *sandbox.Input) *exec.Cmd {
+func buildCmd(dir, metaFile, boxArg string, input *sandbox.Input) *exec.Cmd {
 	// Calculate stuff
 	timeLimit := float64(input.TimeLimit) / float64(time.Second)
+	processes := "-p" // Allow multiple processes
+	if input.MaxProcesses > 0 {
+		processes = fmt.Sprintf("-p%d", input.MaxProcesses)
+	}
+	outputLimit := input.OutputLimit
+	if outputLimit == 0 {
+		outputLimit = sandbox.DefaultOutputLimit
+	}
 
 	// Run the main isolate command
 	cmd := exec.Command(
 		"isolate",
 		"--cg",
+		boxArg,
 		"--run",
 		"-M", metaFile,
 		"-t", fmt.Sprintf("%.1f", timeLimit), // Time limit
 		"-w", fmt.Sprintf("%.1f", 2*timeLimit+1.0), // Wall time
 		"-x", "1.0", // Extra time
-		"-f", "262144", // 256MBs of files
-		"-p", // Allow multiple processes
+		"-f", strconv.Itoa(outputLimit), // Size of files
+		processes,
 		"-s", // Be silent
 		"--env=ONLINE_JUDGE=true",
 		"--env=KJUDGE=true",
 		fmt.Sprintf("--cg-mem=%d", input.MemoryLimit), // total memory
-		"--",
-		input.Command,
 	)
+	for _, env := range input.Env {
+		cmd.Args = append(cmd.Args, "--env="+env)
+	}
+	cmd.Args = append(cmd.Args, "--", input.Command)
 	if len(input.Args) > 0 {
 		cmd.Args = append(cmd.Args, input.Args...)
 	}
@@ -226,10 +273,13 @@ func buildCmd(dir, metaFile string, input *sandbox.Input) *exec.Cmd {
 
 	// Pipe the stdin
 	cmd.Stdin = bytes.NewBuffer(input.Input)
+	if input.Stdin != nil {
+		cmd.Stdin = input.Stdin
+	}
 
 	return cmd
 }
 
 func (s *Runner) cleanup() {
-	_ = exec.Command(s.isolateCommand(), "--cleanup", "--cg").Run()
+	_ = exec.Command(s.isolateCommand(), "--cleanup", "--cg", s.boxArg()).Run()
 }
@@ -12,18 +12,23 @@ package raw
 import (
 	"bytes"
 	"context"
+	"fmt"
 	"log"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/natsukagami/kjudge/worker/sandbox"
+	"github.com/pkg/errors"
 )
 
 // Runner implements worker.Runner.
 type Runner struct {
 	settings sandbox.Settings
+	box      int
 }
 
 var _ sandbox.Runner = (*Runner)(nil)
@@ -45,9 +50,27 @@ func (s *Runner) Settings() *sandbox.Settings {
 	return &s.settings
 }
 
+// Box implements Runner.Box.
+func (s *Runner) Box(id int) sandbox.Runner {
+	return &Runner{settings: s.settings, box: id}
+}
+
+// Helper implements Runner.Helper.
+func (s *Runner) Helper() sandbox.Runner {
+	return s.Box(s.box + sandbox.HelperBoxOffset)
+}
+
 // Run implements Runner.Run
 func (s *Runner) Run(input *sandbox.Input) (*sandbox.Output, error) {
-	dir := os.TempDir()
+	// Each box gets its own folder, so that parallel runs don't overwrite each other's files.
+	// It is emptied before every run, so that the files left by one run do not leak into the next.
+	dir := filepath.Join(os.TempDir(), fmt.Sprintf("kjudge-raw-%d", s.box))
+	if err := os.RemoveAll(dir); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, errors.WithStack(err)
+	}
 
 	if s.Settings().LogSandbox {
 		log.Printf("[SANDBOX] Running %s %v\n", input.Command, input.Args)
@@ -59,7 +82,7 @@ func (s *Runner) Run(input *sandbox.Input) (*sandbox.Output, error) {
 // RunFrom runs the input, assuming that it has write access to "cwd".
 //
 // Raw sandbox assumes that:
-//   - MEMORY LIMITS ARE NOT SET. It always reports a memory usage of 0 (it cannot measure them).
+//   - MEMORY, PROCESS AND OUTPUT SIZE LIMITS ARE NOT SET. It always reports a memory usage of 0 (it cannot measure them).
 //   - THE PROGRAM DOES NOT MESS WITH THE COMPUTER. LMAO
 //   - The folder will be thrown away later.
 func (s *Runner) RunFrom(cwd string, input *sandbox.Input) (*sandbox.Output, error) {
@@ -76,10 +99,16 @@ func (s *Runner) RunFrom(cwd string, input *sandbox.Input) (*sandbox.Output, err
 
 	cmd := exec.CommandContext(ctx, input.Command, input.Args...)
 	cmd.Dir = cwd
-	cmd.Env = []string{"ONLINE_JUDGE=true", "KJUDGE=true"} // No env access
+	cmd.Env = append([]string{"ONLINE_JUDGE=true", "KJUDGE=true"}, input.Env...) // No env access
 	cmd.Stdin = bytes.NewBuffer(input.Input)
+	if input.Stdin != nil {
+		cmd.Stdin = input.Stdin
+	}
 	var stdout, stderr bytes.Buffer
 	cmd.Stdout = &stdout
+	if input.Stdout != nil {
+		cmd.Stdout = input.Stdout
+	}
 	cmd.Stderr = &stderr
 
 	// Collect output BUT don't do it for too long
@@ -96,6 +125,7 @@ func (s *Runner) RunFrom(cwd string, input *sandbox.Input) (*sandbox.Output, err
 		<-done
 		return &sandbox.Output{
 			Success:      false,
+			Status:       sandbox.StatusTimedOut,
 			MemoryUsed:   0,
 			RunningTime:  input.TimeLimit,
 			Stdout:       []byte{},
@@ -104,14 +134,32 @@ func (s *Runner) RunFrom(cwd string, input *sandbox.Input) (*sandbox.Output, err
 		}, nil
 	case commandErr := <-done:
 		runningTime := time.Since(startTime)
-		return &sandbox.Output{
+		output := &sandbox.Output{
 			Success:      commandErr == nil,
 			MemoryUsed:   0,
 			RunningTime:  runningTime,
 			Stdout:       stdout.Bytes(),
 			Stderr:       stderr.Bytes(),
 			ErrorMessage: "",
-		}, nil
+		}
+		if e, ok := commandErr.(*exec.ExitError); ok {
+			if status, ok := e.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+				output.Status = sandbox.StatusSignaled
+				output.Signal = int(status.Signal())
+			} else {
+				output.Status = sandbox.StatusRuntimeError
+				output.ExitCode = e.ExitCode()
+			}
+		} else if commandErr != nil {
+			output.Status = sandbox.StatusInternalError
+			output.ErrorMessage = commandErr.Error()
+		}
+		files, err := input.ReadFiles(cwd)
+		if err != nil {
+			return nil, err
+		}
+		output.Files = files
+		return output, nil
 
 	}
 }
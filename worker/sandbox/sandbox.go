@@ -1,6 +1,8 @@
 package sandbox
 
 import (
+	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"time"
@@ -20,8 +22,17 @@ type Runner interface {
 	Stop() error
 	Settings() *Settings
 	Run(*Input) (*Output, error)
+	// Box returns a Runner with the same settings, that runs commands in the box with the given ID.
+	// Runners with different box IDs can run commands at the same time.
+	Box(id int) Runner
+	// Helper returns a Runner in a box paired with this one, for helper programs (e.g. interactors)
+	// that need to run at the same time as the command in this box.
+	Helper() Runner
 }
 
+// HelperBoxOffset is the offset between a box's ID and its helper box's ID.
+const HelperBoxOffset = 500
+
 // Input is the input to a sandbox.
 type Input struct {
 	Command     string            `json:"command"`      // The passed command
@@ -32,24 +43,114 @@ type Input struct {
 
 	CompiledSource []byte `json:"compiled_source"` // Should be written down to the CWD as a file named "code", as the command expects
 	Input          []byte `json:"input"`
+
+	MaxProcesses int      `json:"max_processes,omitempty"` // The number of processes allowed. 0 means unlimited.
+	OutputLimit  int      `json:"output_limit,omitempty"`  // The size limit of each file written, in KBs. 0 means the default of 256MBs.
+	Env          []string `json:"env,omitempty"`           // Additional environment variables, as "KEY=value"
+	CollectFiles bool     `json:"collect_files,omitempty"` // Whether to return the files in the CWD after running, in Output.Files
+	OutputFiles  []string `json:"output_files,omitempty"`  // The files in the CWD to return after running, in Output.Files, if CollectFiles is not set
+
+	// If set, Stdin and Stdout are connected to the command in place of Input and Output.Stdout,
+	// so that it can talk to another running process (e.g. an interactor).
+	Stdin  io.Reader `json:"-"`
+	Stdout io.Writer `json:"-"`
 }
 
+// Status tells how an unsuccessful command ended, following the "status" entry of isolate's meta files.
+type Status string
+
+// Defined values for Status.
+const (
+	StatusOK            Status = ""   // The command exited zero.
+	StatusRuntimeError  Status = "RE" // The command exited non-zero.
+	StatusSignaled      Status = "SG" // The command was killed by a signal.
+	StatusTimedOut      Status = "TO" // The command ran out of time.
+	StatusInternalError Status = "XX" // The sandbox itself failed.
+)
+
 // Output is the output which the sandbox needs to give back.
 type Output struct {
 	Success     bool          `json:"success"`      // Whether the command exited zero.
+	Status      Status        `json:"status"`       // How the command ended.
+	ExitCode    int           `json:"exit_code"`    // The exit code of the command, 0 if it was killed.
+	Signal      int           `json:"signal"`       // The signal that killed the command, if Status is StatusSignaled.
+	OOMKilled   bool          `json:"oom_killed"`   // Whether the command was killed for using too much memory.
 	RunningTime time.Duration `json:"running_time"` // The running time of the command.
 	MemoryUsed  int           `json:"memory_used"`  // in KBs
 
 	Stdout       []byte `json:"stdout"`
 	Stderr       []byte `json:"stderr"`
 	ErrorMessage string `json:"error_message,omitempty"`
+
+	Files map[string][]byte `json:"files,omitempty"` // The files in the CWD after running asked for by Input.CollectFiles or Input.OutputFiles
+}
+
+// DefaultOutputLimit is the default size limit of each file written by the command, in KBs.
+const DefaultOutputLimit = 262144 // 256MBs
+
+// CollectFiles reads all regular files under cwd, keyed by their path relative to cwd.
+func CollectFiles(cwd string) (map[string][]byte, error) {
+	files := make(map[string][]byte)
+	err := filepath.WalkDir(cwd, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		name, err := filepath.Rel(cwd, path)
+		if err != nil {
+			return err
+		}
+		files[filepath.ToSlash(name)] = content
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "collecting files")
+	}
+	return files, nil
+}
+
+// ReadFiles reads the files asked for by CollectFiles or OutputFiles from cwd, after running the command.
+// Output files that were not written, or are not regular files, are left out.
+func (input *Input) ReadFiles(cwd string) (map[string][]byte, error) {
+	if input.CollectFiles {
+		return CollectFiles(cwd)
+	}
+	if len(input.OutputFiles) == 0 {
+		return nil, nil
+	}
+	files := make(map[string][]byte)
+	for _, name := range input.OutputFiles {
+		path := filepath.Join(cwd, filepath.FromSlash(name))
+		// Lstat, as the command may have replaced the file with a link to somewhere outside of cwd.
+		if info, err := os.Lstat(path); errors.Is(err, fs.ErrNotExist) || (err == nil && !info.Mode().IsRegular()) {
+			continue
+		} else if err != nil {
+			return nil, errors.Wrapf(err, "reading file %s", name)
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, errors.Wrapf(err, "reading file %s", name)
+		}
+		files[name] = content
+	}
+	return files, nil
 }
 
 // CopyTo copies all the files it contains into cwd.
 func (input *Input) CopyTo(cwd string) error {
 	// Copy all the files into "cwd"
 	for name, file := range input.Files {
-		if err := os.WriteFile(filepath.Join(cwd, name), file, 0666); err != nil {
+		path := filepath.Join(cwd, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+			return errors.Wrapf(err, "writing file %s", name)
+		}
+		if err := os.WriteFile(path, file, 0666); err != nil {
 			return errors.Wrapf(err, "writing file %s", name)
 		}
 	}
@@ -2,6 +2,7 @@ package worker
 
 import (
 	"database/sql"
+	"fmt"
 	"log"
 	"math"
 	"time"
@@ -30,6 +31,10 @@ func Score(s *ScoreContext) error {
 	if err != nil {
 		return err
 	}
+	if s.Sub.ScoredOnPretests(s.Contest) {
+		// Until the system tests, the submission is only judged on the pretests.
+		tests = models.PretestGroups(tests)
+	}
 	if compiled, source := s.CompiledSource(); !compiled {
 		// Add a compilation job and re-add ourselves.
 		log.Printf("[WORKER] Submission %v not compiled, creating Compile job.\n", s.Sub.ID)
@@ -40,17 +45,17 @@ func Score(s *ScoreContext) error {
 		if err := s.Sub.Write(s.DB); err != nil {
 			return err
 		}
-		// Update the ProblemResult
-		subs, err := models.GetUserProblemSubmissions(s.DB, s.Sub.UserID, s.Problem.ID)
-		if err != nil {
+		return s.UpdateProblemResult()
+	}
+	missing, skipped := MissingTests(tests, testResults)
+	for _, test := range skipped {
+		result := models.NewSkippedTestResult(s.Sub.ID, test.ID)
+		if err := result.Write(s.DB); err != nil {
 			return err
 		}
-		pr := s.CompareScores(subs)
-		log.Printf("[WORKER] Problem results updated for user %s, problem %d (score = %.1f, penalty = %d)\n", s.Sub.UserID, s.Problem.ID, pr.Score, pr.Penalty)
-
-		return pr.Write(s.DB)
+		testResults[test.ID] = result
 	}
-	if missing := MissingTests(tests, testResults); len(missing) > 0 {
+	if len(missing) > 0 {
 		log.Printf("[WORKER] Submission %v needs to run %d tests before being scored.\n", s.Sub.ID, len(missing))
 		var jobs []*models.Job
 		for _, m := range missing {
@@ -60,39 +65,94 @@ func Score(s *ScoreContext) error {
 		return models.BatchInsertJobs(s.DB, jobs...)
 	}
 
+	if message := judgeErrorOf(tests, testResults); message != "" {
+		// The submission is not scored, so that it does not count against the contestant.
+		log.Printf("[WORKER] Submission %d has a judge error: %s\n", s.Sub.ID, message)
+		s.Sub.Verdict = models.VerdictJudgeError
+		s.Sub.JudgeError = message
+		s.Sub.Score = sql.NullFloat64{}
+		s.Sub.VisibleScore = sql.NullFloat64{}
+		s.Sub.VisibleVerdict = ""
+		s.Sub.Penalty = sql.NullInt64{}
+		if err := s.Sub.Write(s.DB); err != nil {
+			return err
+		}
+		return s.UpdateProblemResult()
+	}
+
 	log.Printf("[WORKER] Scoring submission %d\n", s.Sub.ID)
 	// Calculate the score by summing scores on each test group.
 	s.Sub.Score = sql.NullFloat64{Float64: 0.0, Valid: true}
 	for _, tg := range tests {
-		if !tg.Hidden() {
-			s.Sub.Score.Float64 += tg.ComputeScore(testResults)
-		}
+		s.Sub.Score.Float64 += tg.ComputeScore(testResults)
 	}
 	// Calculate penalty too
 	if err := s.ComputePenalties(s.Sub); err != nil {
 		return err
 	}
 	// Verdict
-	UpdateVerdict(tests, s.Sub)
+	UpdateVerdict(tests, testResults, s.Sub)
+	s.scoreVisible(tests, testResults)
 	// Write the submission's score
 	if err := s.Sub.Write(s.DB); err != nil {
 		return err
 	}
 	log.Printf("[WORKER] Submission %d scored (verdict = %s, score = %.1f). Updating problem results\n", s.Sub.ID, s.Sub.Verdict, s.Sub.Score.Float64)
 
-	// Update the ProblemResult
+	return s.UpdateProblemResult()
+}
+
+// Computes the score and the verdict the contestants see until the contest ends,
+// on the test groups visible during the contest only.
+func (s *ScoreContext) scoreVisible(tests []*models.TestGroupWithTests, results map[int]*models.TestResult) {
+	visibleTests := models.VisibleTestGroups(tests, false)
+	visible := *s.Sub
+	visible.Score = sql.NullFloat64{Float64: 0.0, Valid: true}
+	for _, tg := range visibleTests {
+		visible.Score.Float64 += tg.ComputeScore(results)
+	}
+	UpdateVerdict(visibleTests, results, &visible)
+	if len(visibleTests) == 0 {
+		// Nothing is visible, not even whether the submission is accepted.
+		visible.Verdict = models.VerdictScored
+	}
+	s.Sub.VisibleScore = visible.Score
+	s.Sub.VisibleVerdict = visible.Verdict
+}
+
+// UpdateProblemResult recomputes the ProblemResult of the submission's user on the problem,
+// along with the result the contestants see until the contest ends.
+func (s *ScoreContext) UpdateProblemResult() error {
 	subs, err := models.GetUserProblemSubmissions(s.DB, s.Sub.UserID, s.Problem.ID)
 	if err != nil {
 		return err
 	}
+	visibleSubs := make([]*models.Submission, len(subs))
+	for i, sub := range subs {
+		visibleSubs[i] = sub.DuringContest()
+	}
 	pr := s.CompareScores(subs)
+	pr.SetDuringContest(s.CompareScores(visibleSubs))
 	log.Printf("[WORKER] Problem results updated for user %s, problem %d (score = %.1f, penalty = %d)\n", s.Sub.UserID, s.Problem.ID, pr.Score, pr.Penalty)
 
 	return pr.Write(s.DB)
 }
 
+// Returns a message describing the first test the judge failed on, or "" if there is none.
+func judgeErrorOf(tests []*models.TestGroupWithTests, results map[int]*models.TestResult) string {
+	for _, tg := range tests {
+		for _, test := range tg.Tests {
+			if r, ok := results[test.ID]; ok && r.VerdictCode == models.VerdictCodeJudgeError {
+				return fmt.Sprintf("Test %s of group %s: %s", test.Name, tg.Name, r.Verdict)
+			}
+		}
+	}
+	return ""
+}
+
 // Update the submission's verdict.
-func UpdateVerdict(tests []*models.TestGroupWithTests, sub *models.Submission) {
+// Submissions not getting the full score get the verdict of the first test they fail, if any.
+func UpdateVerdict(tests []*models.TestGroupWithTests, results map[int]*models.TestResult, sub *models.Submission) {
 	score, _, counts := scoreOf(sub)
 	if !counts {
 		sub.Verdict = models.VerdictCompileError
@@ -101,16 +161,20 @@ func UpdateVerdict(tests []*models.TestGroupWithTests, sub *models.Submission) {
 
 	maxPossibleScore := 0.0
 	for _, tg := range tests {
-		if tg.Score > 0 {
-			maxPossibleScore += tg.Score
-		}
+		maxPossibleScore += tg.Score
 	}
 
 	if score == maxPossibleScore {
 		sub.Verdict = models.VerdictAccepted
-	} else {
-		sub.Verdict = models.VerdictScored
+		return
+	}
+	// The test groups hidden during the contest are left out by the caller for the verdict the contestants see
+	// until then, see scoreVisible.
+	if _, r := models.FirstFailedTest(tests, results); r != nil {
+		sub.Verdict = r.VerdictCode.Name()
+		return
 	}
+	sub.Verdict = models.VerdictScored
 }
 
 // TestResults returns the submission's test results, mapped by the test's ID.
@@ -180,6 +244,17 @@ func (s *ScoreContext) CompareScores(subs []*models.Submission) *models.ProblemR
 		subs[i], subs[j] = subs[j], subs[i]
 	}
 
+	if s.Contest.SystemTests && s.Contest.SystemTestsStarted {
+		// Only the system tested submissions count after the system tests start.
+		var systemTested []*models.Submission
+		for _, sub := range subs {
+			if sub.SystemTest {
+				systemTested = append(systemTested, sub)
+			}
+		}
+		subs = systemTested
+	}
+
 getScoredSub:
 	for _, sub := range subs {
 		score, _, counts := scoreOf(sub)
@@ -264,17 +339,28 @@ getScoredSub:
 	}
 }
 
-// MissingTests finds all the tests that are missing a TestResult.
-func MissingTests(tests []*models.TestGroupWithTests, results map[int]*models.TestResult) []*models.Test {
-	var res []*models.Test
+// MissingTests finds all the tests that are missing a TestResult and should be run, along with the ones that should
+// be skipped instead: the missing tests of the groups that stop at their first failure and already have one.
+// The Run jobs of such a group are cancelled once one of its tests fails (see models.CancelGroupRunJobs),
+// so that the tests not run yet are skipped.
+func MissingTests(tests []*models.TestGroupWithTests, results map[int]*models.TestResult) (missing, skipped []*models.Test) {
 	for _, tg := range tests {
+		failed := false
+		var groupMissing []*models.Test
 		for _, test := range tg.Tests {
-			if _, ok := results[test.ID]; !ok {
-				res = append(res, test)
+			if r, ok := results[test.ID]; !ok {
+				groupMissing = append(groupMissing, test)
+			} else if r.Failed() {
+				failed = true
 			}
 		}
+		if failed && tg.StopsAtFirstFailure() {
+			skipped = append(skipped, groupMissing...)
+		} else {
+			missing = append(missing, groupMissing...)
+		}
 	}
-	return res
+	return missing, skipped
 }
 
 // CompiledSource returns the CompiledSource. Returns false when the submission hasn't been compiled.
@@ -0,0 +1,126 @@
+package worker
+
+import (
+	"database/sql"
+	"reflect"
+	"testing"
+
+	"github.com/natsukagami/kjudge/models"
+)
+
+func TestMissingTests(t *testing.T) {
+	group := func(id int, mode models.TestScoringMode, skipAfterFailure bool, testIDs ...int) *models.TestGroupWithTests {
+		tg := &models.TestGroupWithTests{TestGroup: &models.TestGroup{ID: id, ScoringMode: mode, SkipAfterFailure: skipAfterFailure}}
+		for _, id := range testIDs {
+			tg.Tests = append(tg.Tests, &models.Test{ID: id})
+		}
+		return tg
+	}
+	result := func(verdict models.VerdictCode, score float64) *models.TestResult {
+		return &models.TestResult{VerdictCode: verdict, Score: score}
+	}
+	testGroups := []*models.TestGroupWithTests{
+		// Stops at its first failure, and has one: the rest is skipped.
+		group(1, models.TestScoringModeMin, true, 1, 2, 3),
+		// A Judge Error is not a failure of the submission: the rest is still run.
+		group(2, models.TestScoringModeProduct, true, 4, 5),
+		// Sum groups never stop, even when asked to.
+		group(3, models.TestScoringModeSum, true, 6, 7),
+		// Not asked to stop.
+		group(4, models.TestScoringModeMin, false, 8, 9),
+		// A partial score is not a failure.
+		group(5, models.TestScoringModeMin, true, 10, 11),
+		// Nothing run yet.
+		group(6, models.TestScoringModeMin, true, 12, 13),
+	}
+	results := map[int]*models.TestResult{
+		2:  result(models.VerdictCodeWrongAnswer, 0),
+		4:  result(models.VerdictCodeJudgeError, 0),
+		6:  result(models.VerdictCodeWrongAnswer, 0),
+		8:  result(models.VerdictCodeTimeLimitExceeded, 0),
+		10: result(models.VerdictCodePartiallyAccepted, 0.5),
+	}
+	ids := func(tests []*models.Test) []int {
+		res := []int{}
+		for _, test := range tests {
+			res = append(res, test.ID)
+		}
+		return res
+	}
+
+	missing, skipped := MissingTests(testGroups, results)
+	if got, want := ids(missing), []int{5, 7, 9, 11, 12, 13}; !reflect.DeepEqual(got, want) {
+		t.Errorf("missing tests: got %v, expected %v", got, want)
+	}
+	if got, want := ids(skipped), []int{1, 3}; !reflect.DeepEqual(got, want) {
+		t.Errorf("skipped tests: got %v, expected %v", got, want)
+	}
+
+	// Once the skipped tests get their results, nothing is left to do for the group.
+	for _, id := range []int{1, 3} {
+		results[id] = models.NewSkippedTestResult(0, id)
+	}
+	missing, skipped = MissingTests(testGroups[:1], results)
+	if len(missing) != 0 || len(skipped) != 0 {
+		t.Errorf("expected nothing left, got missing %v, skipped %v", ids(missing), ids(skipped))
+	}
+}
+
+func TestUpdateVerdict(t *testing.T) {
+	group := func(visibility models.TestGroupVisibility, testIDs ...int) *models.TestGroupWithTests {
+		tg := &models.TestGroupWithTests{TestGroup: &models.TestGroup{Score: 50, ScoringMode: models.TestScoringModeSum, Visibility: visibility}}
+		for _, id := range testIDs {
+			tg.Tests = append(tg.Tests, &models.Test{ID: id})
+		}
+		return tg
+	}
+	tests := []*models.TestGroupWithTests{
+		group(models.TestGroupVisibilityVisible, 1, 2),
+		group(models.TestGroupVisibilityHiddenDuringContest, 3, 4),
+	}
+	results := map[int]*models.TestResult{
+		1: {VerdictCode: models.VerdictCodeAccepted, Score: 1},
+		2: {VerdictCode: models.VerdictCodeAccepted, Score: 1},
+		3: {VerdictCode: models.VerdictCodeAccepted, Score: 1},
+		4: {VerdictCode: models.VerdictCodeTimeLimitExceeded, Score: 0},
+	}
+	// Fails only in the test group hidden during the contest.
+	sub := &models.Submission{CompiledSource: []byte("binary"), Penalty: sql.NullInt64{Valid: true},
+		Score: sql.NullFloat64{Float64: 75, Valid: true}}
+	UpdateVerdict(tests, results, sub)
+	if want := models.VerdictCodeTimeLimitExceeded.Name(); sub.Verdict != want {
+		t.Errorf("expected verdict %q, got %q", want, sub.Verdict)
+	}
+	(&ScoreContext{Sub: sub}).scoreVisible(tests, results)
+	if sub.VisibleVerdict != models.VerdictAccepted || sub.VisibleScore.Float64 != 50 {
+		t.Errorf("expected the contestants to see Accepted with 50 points during the contest, got %q with %v",
+			sub.VisibleVerdict, sub.VisibleScore.Float64)
+	}
+}
+
+func TestScoreVisibleDependencies(t *testing.T) {
+	hidden := &models.TestGroupWithTests{
+		TestGroup: &models.TestGroup{ID: 1, Score: 50, ScoringMode: models.TestScoringModeMin, Visibility: models.TestGroupVisibilityHiddenDuringContest},
+		Tests:     []*models.Test{{ID: 1}},
+	}
+	visible := &models.TestGroupWithTests{
+		TestGroup:    &models.TestGroup{ID: 2, Score: 50, ScoringMode: models.TestScoringModeMin, Visibility: models.TestGroupVisibilityVisible},
+		Tests:        []*models.Test{{ID: 2}},
+		Dependencies: []*models.TestGroupWithTests{hidden},
+	}
+	results := map[int]*models.TestResult{
+		1: {VerdictCode: models.VerdictCodeWrongAnswer, Score: 0},
+		2: {VerdictCode: models.VerdictCodeAccepted, Score: 1},
+	}
+	sub := &models.Submission{CompiledSource: []byte("binary"), Penalty: sql.NullInt64{Valid: true},
+		Score: sql.NullFloat64{Valid: true}}
+	(&ScoreContext{Sub: sub}).scoreVisible([]*models.TestGroupWithTests{hidden, visible}, results)
+	// The failure in the hidden group does not show during the contest.
+	if sub.VisibleScore.Float64 != 50 || sub.VisibleVerdict != models.VerdictAccepted {
+		t.Errorf("expected the contestants to see Accepted with 50 points during the contest, got %q with %v",
+			sub.VisibleVerdict, sub.VisibleScore.Float64)
+	}
+	if score := visible.ComputeScore(results); score != 0 {
+		t.Errorf("expected the group to be capped by its hidden dependency once the contest ends, got %v", score)
+	}
+}
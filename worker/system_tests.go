@@ -0,0 +1,49 @@
+package worker
+
+import (
+	"log"
+	"time"
+
+	"github.com/natsukagami/kjudge/db"
+	"github.com/natsukagami/kjudge/models"
+	"github.com/pkg/errors"
+)
+
+// How often the ended contests are checked for system tests to start.
+const systemTestsCheckInterval = 10 * time.Second
+
+// WatchSystemTests starts the system tests of the contests with system tests as soon as they end.
+// It is blocking, so might wanna "go run" it.
+func WatchSystemTests(db *db.DB) {
+	for range time.Tick(systemTestsCheckInterval) {
+		if err := startEndedSystemTests(db); err != nil {
+			log.Printf("[SYSTEM TESTS] Starting the system tests failed: %+v\n", err)
+		}
+	}
+}
+
+func startEndedSystemTests(d *db.DB) error {
+	contests, err := models.GetContestsAwaitingSystemTests(d)
+	if err != nil {
+		return err
+	}
+	for _, contest := range contests {
+		if err := startSystemTests(d, contest); err != nil {
+			return err
+		}
+		log.Printf("[SYSTEM TESTS] Started the system tests of contest %d (%s)\n", contest.ID, contest.Name)
+	}
+	return nil
+}
+
+func startSystemTests(d *db.DB, contest *models.Contest) error {
+	tx, err := d.Beginx()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer db.Rollback(tx)
+	if err := models.StartSystemTests(tx, contest); err != nil {
+		return err
+	}
+	return errors.WithStack(tx.Commit())
+}
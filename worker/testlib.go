@@ -0,0 +1,37 @@
+package worker
+
+import (
+	"bytes"
+	"io/fs"
+
+	"github.com/pkg/errors"
+
+	"github.com/natsukagami/kjudge/embed"
+	"github.com/natsukagami/kjudge/models"
+)
+
+// TestlibFilename is the name of testlib's header, which testlib checkers include.
+const TestlibFilename = "testlib.h"
+
+// The testlib header in the embedded content, fetched by scripts/fetch_testlib.sh.
+const testlibAsset = "assets/testlib.h"
+
+// withTestlib adds the bundled testlib header to the files, unless the problem has its own.
+// kjudge can be built without the header, in which case compiling a source including it fails with an explanation,
+// instead of the compiler's "No such file or directory".
+func withTestlib(source *models.File, files []*models.File) ([]*models.File, error) {
+	for _, f := range files {
+		if f.Filename == TestlibFilename {
+			return files, nil
+		}
+	}
+	content, err := fs.ReadFile(embed.Content, testlibAsset)
+	if err != nil {
+		if bytes.Contains(source.Content, []byte(TestlibFilename)) {
+			return nil, errors.Errorf("%s includes %s, which this build of kjudge does not have: upload it as a file of the problem, "+
+				"or fetch it with scripts/fetch_testlib.sh and rebuild kjudge", source.Filename, TestlibFilename)
+		}
+		return files, nil
+	}
+	return append(files, &models.File{Filename: TestlibFilename, Content: content}), nil
+}
@@ -0,0 +1,113 @@
+package worker
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/natsukagami/kjudge/db"
+	"github.com/natsukagami/kjudge/models"
+	"github.com/natsukagami/kjudge/worker/sandbox"
+	"github.com/pkg/errors"
+)
+
+// The filename of the "validator" binary, which checks the tests' inputs against the problem's constraints.
+// Validators read the test input on their standard input, and are run with the arguments "--group <name>"
+// (the test group's name), like testlib validators. The input is valid if the validator exits zero,
+// otherwise its standard error tells what is wrong with it.
+// Validators are run in the background (see models.AdminTaskTypeValidate) when tests are uploaded, and on demand.
+const ValidatorFilename = "validator"
+
+// The amount of the validator's standard error kept as the validation message, in bytes.
+const validationMessageLength = 1024
+
+// GetValidator returns the problem's validator, or nil if there is none.
+func GetValidator(db db.DBContext, problemID int) (*models.File, error) {
+	file, err := models.GetFileWithName(db, problemID, ValidatorFilename)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return file, err
+}
+
+// ValidateReport counts the results of the validated tests.
+type ValidateReport struct {
+	Valid   int
+	Invalid int
+	// The tests the validator failed to run on.
+	Failed int
+}
+
+// ValidateTests runs the validator on the inputs of the test groups' tests that are not validated yet
+// (see models.Test.Validation), writing down the results.
+func ValidateTests(s sandbox.Runner, db db.DBContext, validator *models.File, testGroups []*models.TestGroupWithTests) (*ValidateReport, error) {
+	report := &ValidateReport{}
+	for _, tg := range testGroups {
+		for _, test := range tg.Tests {
+			if test.Validation != models.TestValidationNone {
+				continue
+			}
+			validateTest(s, validator, tg.TestGroup, test)
+			switch test.Validation {
+			case models.TestValidationValid:
+				report.Valid++
+			case models.TestValidationInvalid:
+				report.Invalid++
+			default:
+				report.Failed++
+			}
+			if _, err := db.Exec("UPDATE tests SET validation = ?, validation_message = ? WHERE id = ?",
+				test.Validation, test.ValidationMessage, test.ID); err != nil {
+				return nil, errors.WithStack(err)
+			}
+		}
+	}
+	return report, nil
+}
+
+// Runs the validator on the test's input, putting the result into the test.
+// Only a validator exiting with a non-zero code rejects the input; when the validator itself does not finish
+// properly (e.g. it times out, or the sandbox fails), the test gets a validator error instead.
+func validateTest(s sandbox.Runner, validator *models.File, tg *models.TestGroup, test *models.Test) {
+	log.Printf("[VALIDATE] Validating [test `%v`, group `%v`]\n", test.Name, tg.Name)
+	output, err := s.Run(&sandbox.Input{
+		Command:     "code",
+		Args:        []string{"--group", tg.Name},
+		Input:       test.Input,
+		TimeLimit:   20 * time.Second,
+		MemoryLimit: (1 << 20), // 1 GB
+
+		CompiledSource: validator.Content,
+	})
+	if err != nil {
+		test.Validation = models.TestValidationError
+		test.ValidationMessage = fmt.Sprintf("The validator could not be run: %v", err)
+		return
+	}
+	if output.Success {
+		test.Validation = models.TestValidationValid
+		test.ValidationMessage = ""
+		return
+	}
+	message := bytes.TrimSpace(output.Stderr)
+	if len(message) > validationMessageLength {
+		message = message[:validationMessageLength]
+	}
+	switch {
+	case output.Status == sandbox.StatusRuntimeError && len(message) > 0:
+		test.Validation = models.TestValidationInvalid
+		test.ValidationMessage = string(message)
+	case output.Status == sandbox.StatusRuntimeError:
+		test.Validation = models.TestValidationInvalid
+		test.ValidationMessage = fmt.Sprintf("The validator exited with code %d", output.ExitCode)
+	default:
+		// The validator itself did not finish properly.
+		test.Validation = models.TestValidationError
+		test.ValidationMessage = fmt.Sprintf("The validator failed (%s)", output.Status)
+		if output.ErrorMessage != "" {
+			test.ValidationMessage += ": " + output.ErrorMessage
+		}
+	}
+}